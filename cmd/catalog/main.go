@@ -0,0 +1,140 @@
+// Command catalog imports products into, and exports them from, the
+// server's database as CSV or JSON. Run it from the server's working
+// directory.
+//
+// Usage:
+//
+//	catalog import [-format csv|json] [-dry-run] products.csv
+//	catalog export [-format csv|json] [-o products.csv]
+//
+// The format defaults to the file's extension, or JSON. An import upserts
+// every row in one transaction and prints a per-row report; nothing is kept if
+// any row fails.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ravip18596/order-food-online/internal/catalog"
+	db "github.com/ravip18596/order-food-online/internal/database"
+	repo "github.com/ravip18596/order-food-online/internal/repository"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "import":
+		importProducts(os.Args[2:])
+	case "export":
+		exportProducts(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n  %[1]s import [-format csv|json] [-dry-run] file\n  %[1]s export [-format csv|json] [-o file]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func importProducts(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "input format, csv or json (default: from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "check the import without keeping it")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	records, err := catalog.Decode(f, fileFormat(*format, path))
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	products := openProducts()
+	defer db.Close()
+
+	report, err := catalog.Import(products, records, *dryRun)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func exportProducts(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "", "output format, csv or json (default: from the file extension)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		usage()
+	}
+
+	products := openProducts()
+	defer db.Close()
+
+	all, err := products.GetAll()
+	if err != nil {
+		log.Fatalf("Failed to read products: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := catalog.Encode(w, fileFormat(*format, *out), all); err != nil {
+		log.Fatalf("Failed to write products: %v", err)
+	}
+}
+
+// fileFormat returns the explicit format, or the one implied by path's
+// extension.
+func fileFormat(format, path string) string {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	f, err := catalog.ParseFormat(format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+func openProducts() *repo.ProductRepository {
+	if err := db.InitDB(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	return repo.NewProductRepository(db.DB)
+}
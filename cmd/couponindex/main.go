@@ -0,0 +1,52 @@
+// Command couponindex compiles coupon base files into a binary index that the
+// server can memory-map at startup instead of scanning the raw files.
+//
+// Usage:
+//
+//	couponindex -o coupons.idx couponbase1.gz couponbase2.gz couponbase3.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ravip18596/order-food-online/internal/coupon"
+)
+
+func main() {
+	out := flag.String("o", "coupons.idx", "output index file")
+	minLen := flag.Int("min-length", coupon.MinCodeLength, "shortest code kept in the index")
+	maxLen := flag.Int("max-length", coupon.MaxCodeLength, "longest code kept in the index")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] couponfile...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *minLen < 1 || *maxLen > coupon.MaxCodeLength || *minLen > *maxLen {
+		log.Fatalf("Invalid length bounds %d..%d (max %d)", *minLen, *maxLen, coupon.MaxCodeLength)
+	}
+
+	start := time.Now()
+	b := coupon.NewBuilder(*minLen, *maxLen)
+	for _, path := range flag.Args() {
+		if err := b.AddFile(path); err != nil {
+			log.Fatalf("Failed to load %s: %v", path, err)
+		}
+	}
+	idx := b.Index()
+
+	if err := idx.WriteFile(*out); err != nil {
+		log.Fatalf("Failed to write index: %v", err)
+	}
+
+	log.Printf("Wrote %s: %d codes from %d files in %s",
+		*out, idx.Len(), idx.Files(), time.Since(start).Round(time.Millisecond))
+}
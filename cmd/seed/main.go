@@ -0,0 +1,58 @@
+// Command seed loads the demo catalog, products "1".."9", into the server's
+// database. Run it from the server's working directory; running it again
+// resets the demo products to the fixture without duplicating them.
+//
+// Usage:
+//
+//	seed [-dry-run]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ravip18596/order-food-online/internal/catalog"
+	db "github.com/ravip18596/order-food-online/internal/database"
+	repo "github.com/ravip18596/order-food-online/internal/repository"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "check the fixture against the database without changing it")
+	flag.Parse()
+
+	if err := db.InitDB(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if err := seed(repo.NewProductRepository(db.DB), *dryRun); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func seed(products *repo.ProductRepository, dryRun bool) error {
+	records, version, err := catalog.Demo()
+	if err != nil {
+		return err
+	}
+
+	report, err := catalog.Import(products, records, dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range report.Errors {
+		log.Printf("Product %s: %s", rowErr.ID, rowErr.Error)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("seeding demo catalog v%d failed for %d of %d products", version, report.Failed, report.Rows)
+	}
+
+	verb := "Seeded"
+	if dryRun {
+		verb = "Checked"
+	}
+	log.Printf("%s demo catalog v%d: %d created, %d updated", verb, version, report.Created, report.Updated)
+	return nil
+}
@@ -1,26 +1,71 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
+	"github.com/ravip18596/order-food-online/internal/coupon"
 	db "github.com/ravip18596/order-food-online/internal/database"
 	handler "github.com/ravip18596/order-food-online/internal/handler"
+	"github.com/ravip18596/order-food-online/internal/images"
 	repo "github.com/ravip18596/order-food-online/internal/repository"
 )
 
+// defaultPromotionsFile is loaded when it exists unless -promotions names
+// another file.
+const defaultPromotionsFile = "promotions.json"
+
 func main() {
+	policy := coupon.DefaultPolicy()
+	couponIndex := flag.String("coupon-index", "", "prebuilt coupon index file to memory-map instead of scanning the coupon bases")
+	couponFiles := flag.String("coupon-files", "couponbase1,couponbase2,couponbase3", "comma-separated coupon base files")
+	flag.IntVar(&policy.MinFiles, "coupon-min-files", policy.MinFiles, "minimum number of coupon files a valid code appears in")
+	flag.IntVar(&policy.MaxFiles, "coupon-max-files", policy.MaxFiles, "maximum number of coupon files a valid code appears in (0 = no limit)")
+	flag.IntVar(&policy.MinLength, "coupon-min-length", policy.MinLength, "minimum coupon code length")
+	flag.IntVar(&policy.MaxLength, "coupon-max-length", policy.MaxLength, "maximum coupon code length")
+	flag.StringVar(&policy.Charset, "coupon-charset", policy.Charset, "characters allowed in coupon codes (empty = any)")
+	storeTimezone := flag.String("store-timezone", "Local", "IANA timezone product availability windows are in, e.g. Europe/London")
+	idempotencyKeyTTL := flag.Duration("idempotency-key-ttl", 24*time.Hour, "how long POST /order responses are kept for retries with the same Idempotency-Key")
+	stacking := flag.String("coupon-stacking", string(coupon.StackExclusive), "how several coupon codes on one order combine: exclusive, stackable or best-of")
+	adminToken := flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "bearer token for the admin API (default $ADMIN_TOKEN; the API is disabled without one)")
+	customerHeader := flag.String("customer-id-header", "", "request header carrying the customer ID verified by an authenticating proxy; per-customer coupon limits need it")
+	promotionsFile := flag.String("promotions", defaultPromotionsFile, "JSON file mapping coupon codes to discounts; without one every valid code gets 10% off")
+	flag.Parse()
+
+	if err := policy.Validate(); err != nil {
+		log.Fatalf("Invalid coupon policy: %v", err)
+	}
+
+	if *idempotencyKeyTTL <= repo.IdempotencyLockTimeout {
+		log.Fatalf("Invalid idempotency key TTL: %v must be longer than the %v a key stays locked",
+			*idempotencyKeyTTL, repo.IdempotencyLockTimeout)
+	}
+
+	stackingMode, err := coupon.ParseStacking(*stacking)
+	if err != nil {
+		log.Fatalf("Invalid coupon stacking: %v", err)
+	}
+
+	location, err := time.LoadLocation(*storeTimezone)
+	if err != nil {
+		log.Fatalf("Invalid store timezone: %v", err)
+	}
+
 	// Initialize database
 	if err := db.InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -28,27 +73,32 @@ func main() {
 	defer db.Close()
 
 	// Load coupon codes
-	set := make(map[string][]int)
-	err := loadSetFromFile("couponbase1", set, 1)
-	if err != nil {
-		log.Fatalf("Failed to load couponbase1: %v", err)
+	loader := func() (*coupon.Index, error) {
+		return loadCoupons(*couponIndex, splitList(*couponFiles), policy)
 	}
-	err = loadSetFromFile("couponbase2", set, 2)
+	idx, err := loader()
 	if err != nil {
-		log.Fatalf("Failed to load couponbase2: %v", err)
+		log.Fatalf("Failed to load coupon bases: %v", err)
 	}
-	err = loadSetFromFile("couponbase3", set, 3)
+	log.Printf("Coupon index loaded with %d codes", idx.Len())
+	coupons := coupon.NewStore(idx, loader)
+	defer coupons.Close()
+
+	promotions, err := loadPromotions(*promotionsFile)
 	if err != nil {
-		log.Fatalf("Failed to load couponbase3: %v", err)
+		log.Fatalf("Failed to load promotions: %v", err)
 	}
-	fmt.Println("Coupon codes loaded into sets")
 
 	// Initialize repositories
 	productRepo := repo.NewProductRepository(db.DB)
+	categoryRepo := repo.NewCategoryRepository(db.DB)
 	orderRepo := repo.NewOrderRepository(db.DB)
+	promoRepo := repo.NewPromoCodeRepository(db.DB)
+	idempotencyRepo := repo.NewIdempotencyRepository(db.DB, *idempotencyKeyTTL)
 
 	// Initialize handler with repositories
-	h := handler.NewHandler(productRepo, orderRepo, set)
+	h := handler.NewHandler(productRepo, categoryRepo, orderRepo, promoRepo, idempotencyRepo, coupons, policy,
+		promotions, stackingMode, *customerHeader, location, images.NewStore(filepath.Join("data", "images")))
 
 	// Create a new router
 	r := mux.NewRouter()
@@ -59,6 +109,11 @@ func main() {
 
 	// Register routes
 	h.RegisterRoutes(r)
+	if *adminToken != "" {
+		h.RegisterAdminRoutes(r, *adminToken)
+	} else {
+		log.Println("No admin token set; the admin API is disabled")
+	}
 
 	// HTTP server with timeouts
 	srv := &http.Server{
@@ -77,6 +132,18 @@ func main() {
 		}
 	}()
 
+	// Rebuild the coupon index on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("SIGHUP received, reloading coupon index")
+			if err := coupons.ReloadAsync(); err != nil {
+				log.Printf("Coupon reload not started: %v", err)
+			}
+		}
+	}()
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -94,70 +161,71 @@ func main() {
 	log.Println("Server stopped")
 }
 
-func loadSetFromFile(path string, uniqueWords map[string][]int, fileNo int) error {
-	// A mutex to protect the uniqueWords map from concurrent access by multiple goroutines.
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	file, err := os.Open(path)
+// loadCoupons memory-maps the prebuilt index at indexPath when given, and
+// otherwise builds the index by scanning the coupon files.
+func loadCoupons(indexPath string, files []string, policy coupon.Policy) (*coupon.Index, error) {
+	var idx *coupon.Index
+	var err error
+	if indexPath != "" {
+		idx, err = coupon.OpenIndex(indexPath)
+	} else {
+		paths := make([]string, len(files))
+		for i, file := range files {
+			paths[i] = resolveCouponPath(file)
+		}
+		idx, err = coupon.LoadFiles(policy.MinLength, policy.MaxLength, paths...)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
 
-	// A buffered channel
-	lineChan := make(chan string, 100)
+	if !idx.Covers(policy.MinLength, policy.MaxLength) {
+		idx.Close()
+		return nil, fmt.Errorf("coupon index does not cover code lengths %d..%d; rebuild it with matching bounds",
+			policy.MinLength, policy.MaxLength)
+	}
+	return idx, nil
+}
 
-	// --- PRODUCER GOROUTINE ---
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(lineChan) // Close the channel when reading is done
+// loadPromotions reads the promotions file at path. The default file may be
+// missing, as may an empty path, in which case every valid code gets 10% off.
+func loadPromotions(path string) (*coupon.Promotions, error) {
+	if path == "" {
+		log.Println("No promotions file; every valid coupon code gets 10% off")
+		return coupon.DefaultPromotions(), nil
+	}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			lineChan <- scanner.Text()
-		}
+	promotions, err := coupon.LoadPromotions(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultPromotionsFile {
+		log.Printf("No %s; every valid coupon code gets 10%% off", path)
+		return coupon.DefaultPromotions(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Promotions loaded from %s", path)
+	return promotions, nil
+}
 
-		if err := scanner.Err(); err != nil {
-			log.Printf("Scanner error: %v", err)
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-	}()
-
-	// --- CONSUMER GOROUTINES ---
-	numWorkers := 3 // Adjust based on your system's CPU cores
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-
-			// Process lines from the channel until it's closed
-			for word := range lineChan {
-				// using a mutex to prevent race conditions
-				mu.Lock()
-				if _, ok := uniqueWords[word]; !ok {
-					uniqueWords[word] = append(uniqueWords[word], fileNo)
-				} else {
-					fileNos, _ := uniqueWords[word]
-					if !containsInt(fileNos, fileNo) {
-						uniqueWords[word] = append(uniqueWords[word], fileNo)
-					}
-				}
-				mu.Unlock()
-			}
-		}(i)
 	}
-
-	wg.Wait()
-	fmt.Println("Set loaded from path:" + path)
-	return nil
+	return items
 }
 
-func containsInt(slice []int, v int) bool {
-	for _, x := range slice {
-		if x == v {
-			return true
-		}
+// resolveCouponPath returns path if it exists, otherwise the gzip-compressed
+// variant (path + ".gz") as shipped in the official coupon artifacts.
+func resolveCouponPath(path string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if _, err := os.Stat(path + ".gz"); err == nil {
+		return path + ".gz"
 	}
-	return false
+	return path
 }
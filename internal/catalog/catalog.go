@@ -0,0 +1,103 @@
+// Package catalog validates products and moves them in and out of the
+// catalog in bulk, as CSV or JSON.
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/schedule"
+)
+
+// Bulk formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ParseFormat checks a format name, defaulting to JSON.
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("format must be %s or %s", FormatCSV, FormatJSON)
+	}
+}
+
+// Validate checks that a product can be stored. It normalises modifier
+// groups as ValidateModifierGroups does.
+func Validate(product *model.Product) error {
+	if product.Name == "" || product.Price <= 0 || (strings.TrimSpace(product.Category) == "" && product.CategoryID == "") {
+		return errors.New("Name, price, and category are required fields")
+	}
+	if product.Stock != nil && *product.Stock < 0 {
+		return errors.New("Stock cannot be negative")
+	}
+	if err := ValidateModifierGroups(product.ModifierGroups); err != nil {
+		return err
+	}
+	if _, err := schedule.Parse(product.Availability); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateModifierGroups checks a product's modifier group definitions. A
+// required group with no minimum is given a minimum of one selection.
+func ValidateModifierGroups(groups []model.ModifierGroup) error {
+	ids := make(map[string]bool)
+	unique := func(id string) bool {
+		if id == "" {
+			return true
+		}
+		if ids[id] {
+			return false
+		}
+		ids[id] = true
+		return true
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		group.Name = strings.TrimSpace(group.Name)
+		if group.Name == "" {
+			return errors.New("modifier group name is required")
+		}
+		if !unique(group.ID) {
+			return fmt.Errorf("duplicate id %q in modifier groups", group.ID)
+		}
+		if len(group.Modifiers) == 0 {
+			return fmt.Errorf("modifier group %q has no modifiers", group.Name)
+		}
+		if group.MinSelect < 0 || group.MaxSelect < 0 {
+			return fmt.Errorf("modifier group %q: selection limits cannot be negative", group.Name)
+		}
+		if group.Required && group.MinSelect == 0 {
+			group.MinSelect = 1
+		}
+		if group.MinSelect > len(group.Modifiers) {
+			return fmt.Errorf("modifier group %q: minSelect exceeds the number of modifiers", group.Name)
+		}
+		if group.MaxSelect > 0 && group.MaxSelect < group.MinSelect {
+			return fmt.Errorf("modifier group %q: maxSelect is less than minSelect", group.Name)
+		}
+
+		for j := range group.Modifiers {
+			modifier := &group.Modifiers[j]
+			modifier.Name = strings.TrimSpace(modifier.Name)
+			if modifier.Name == "" {
+				return fmt.Errorf("modifier group %q: modifier name is required", group.Name)
+			}
+			if !unique(modifier.ID) {
+				return fmt.Errorf("duplicate id %q in modifier groups", modifier.ID)
+			}
+		}
+	}
+
+	return nil
+}
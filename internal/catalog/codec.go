@@ -0,0 +1,222 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// csvColumns are the CSV columns, in export order. Modifier groups and
+// availability windows are JSON-encoded in their cells.
+var csvColumns = []string{
+	"id", "name", "description", "price", "category", "category_id", "stock",
+	"image_thumbnail", "image_mobile", "image_tablet", "image_desktop",
+	"modifier_groups", "availability",
+}
+
+// Record is one product read from an import file. Row is the line number in
+// a CSV file or the position in a JSON array, counting from 1. Err is set if
+// the row could not be read.
+type Record struct {
+	Row     int
+	Product *model.Product
+	Err     error
+}
+
+// Decode reads products in the given format. Rows that cannot be read are
+// returned with their error; the error result is for unreadable input.
+func Decode(r io.Reader, format string) ([]Record, error) {
+	if format == FormatCSV {
+		return decodeCSV(r)
+	}
+	return decodeJSON(r)
+}
+
+// Encode writes products in the given format.
+func Encode(w io.Writer, format string, products []*model.Product) error {
+	if format == FormatCSV {
+		return encodeCSV(w, products)
+	}
+	return json.NewEncoder(w).Encode(products)
+}
+
+func decodeJSON(r io.Reader) ([]Record, error) {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON: expected an array of products: %w", err)
+	}
+
+	records := make([]Record, len(rows))
+	for i, row := range rows {
+		var product model.Product
+		records[i] = Record{Row: i + 1, Product: &product}
+		if err := json.Unmarshal(row, &product); err != nil {
+			records[i].Err = err
+		}
+	}
+	return records, nil
+}
+
+func decodeCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []Record{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isColumn(name) {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		columns[name] = i
+	}
+
+	var records []Record
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := Record{Row: line}
+		if len(fields) != len(header) {
+			record.Err = fmt.Errorf("expected %d fields, got %d", len(header), len(fields))
+		} else {
+			record.Product, record.Err = parseCSVRow(columns, fields)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseCSVRow(columns map[string]int, fields []string) (*model.Product, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	product := &model.Product{
+		ID:          get("id"),
+		Name:        get("name"),
+		Description: get("description"),
+		Category:    get("category"),
+		CategoryID:  get("category_id"),
+		Image: model.Image{
+			Thumbnail: get("image_thumbnail"),
+			Mobile:    get("image_mobile"),
+			Tablet:    get("image_tablet"),
+			Desktop:   get("image_desktop"),
+		},
+	}
+
+	if v := get("price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("price must be a number")
+		}
+		product.Price = price
+	}
+	if v := get("stock"); v != "" {
+		stock, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("stock must be a whole number")
+		}
+		product.Stock = &stock
+	}
+	if v := get("modifier_groups"); v != "" {
+		if err := json.Unmarshal([]byte(v), &product.ModifierGroups); err != nil {
+			return nil, fmt.Errorf("modifier_groups: %w", err)
+		}
+	}
+	if v := get("availability"); v != "" {
+		if err := json.Unmarshal([]byte(v), &product.Availability); err != nil {
+			return nil, fmt.Errorf("availability: %w", err)
+		}
+	}
+	return product, nil
+}
+
+func encodeCSV(w io.Writer, products []*model.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		stock := ""
+		if product.Stock != nil {
+			stock = strconv.Itoa(*product.Stock)
+		}
+		modifierGroups, err := jsonCell(product.ModifierGroups, len(product.ModifierGroups))
+		if err != nil {
+			return err
+		}
+		availability, err := jsonCell(product.Availability, len(product.Availability))
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write([]string{
+			product.ID,
+			product.Name,
+			product.Description,
+			strconv.FormatFloat(product.Price, 'f', -1, 64),
+			product.Category,
+			product.CategoryID,
+			stock,
+			product.Image.Thumbnail,
+			product.Image.Mobile,
+			product.Image.Tablet,
+			product.Image.Desktop,
+			modifierGroups,
+			availability,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonCell encodes a list for a CSV cell, leaving empty lists blank.
+func jsonCell(v interface{}, n int) (string, error) {
+	if n == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func isColumn(name string) bool {
+	for _, column := range csvColumns {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// demoFixture holds the products of the demo server. Bump its version
+// whenever the products change.
+//
+//go:embed demo.json
+var demoFixture []byte
+
+// Demo returns the demo catalog as import records, with the fixture version.
+// Importing it is idempotent: the products keep their IDs "1".."9".
+func Demo() ([]Record, int, error) {
+	var fixture struct {
+		Version  int             `json:"version"`
+		Products []model.Product `json:"products"`
+	}
+	if err := json.Unmarshal(demoFixture, &fixture); err != nil {
+		return nil, 0, fmt.Errorf("invalid demo fixture: %w", err)
+	}
+
+	records := make([]Record, len(fixture.Products))
+	for i := range fixture.Products {
+		records[i] = Record{Row: i + 1, Product: &fixture.Products[i]}
+	}
+	return records, fixture.Version, nil
+}
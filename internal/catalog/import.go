@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// Report summarises an import. Created and Updated count the rows that were,
+// or in a dry run or failed import would have been, stored. The import is
+// only Committed if no row failed and it was not a dry run.
+type Report struct {
+	DryRun    bool       `json:"dryRun"`
+	Committed bool       `json:"committed"`
+	Rows      int        `json:"rows"`
+	Created   int        `json:"created"`
+	Updated   int        `json:"updated"`
+	Failed    int        `json:"failed"`
+	Errors    []RowError `json:"errors,omitempty"`
+}
+
+// RowError is the reason a row could not be imported.
+type RowError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// Import validates records and upserts the valid ones into the catalog in a
+// single transaction. Nothing is kept unless every row is stored and dryRun is
+// false, so a failed import can be fixed and retried as a whole.
+func Import(products *repository.ProductRepository, records []Record, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Rows: len(records)}
+	fail := func(record Record, id string, err error) {
+		report.Failed++
+		report.Errors = append(report.Errors, RowError{Row: record.Row, ID: id, Error: err.Error()})
+	}
+
+	var valid []Record
+	rows := make(map[string]int)
+	for _, record := range records {
+		if record.Err != nil {
+			fail(record, "", record.Err)
+			continue
+		}
+		if err := Validate(record.Product); err != nil {
+			fail(record, record.Product.ID, err)
+			continue
+		}
+		if id := record.Product.ID; id != "" {
+			if row, ok := rows[id]; ok {
+				fail(record, id, fmt.Errorf("id %s is also used on row %d", id, row))
+				continue
+			}
+			rows[id] = record.Row
+		}
+		valid = append(valid, record)
+	}
+
+	// Keep the given IDs for the report; new products are assigned one
+	batch := make([]*model.Product, len(valid))
+	ids := make([]string, len(valid))
+	for i, record := range valid {
+		batch[i] = record.Product
+		ids[i] = record.Product.ID
+	}
+
+	results, committed, err := products.Import(batch, !dryRun && report.Failed == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		switch {
+		case result.Err != nil:
+			fail(valid[i], ids[i], result.Err)
+		case result.Created:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+	report.Committed = committed
+	return report, nil
+}
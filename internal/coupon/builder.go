@@ -0,0 +1,223 @@
+package coupon
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+)
+
+// compactEvery is how many keys are read from a file before the ones read so
+// far are sorted and deduplicated.
+const compactEvery = 1 << 20
+
+// Builder accumulates coupon files into an Index. Each file is reduced to a
+// sorted, deduplicated key array as it is read, deduplicating again whenever
+// the keys read since double, and merged into the running result. Peak memory
+// thus stays proportional to the distinct codes rather than the raw text.
+type Builder struct {
+	minLen int
+	maxLen int
+	keys   []uint64
+	counts []uint8
+	files  int
+}
+
+// NewBuilder returns a Builder that keeps only codes whose length lies within
+// [minLen, maxLen]. maxLen is capped at MaxCodeLength.
+func NewBuilder(minLen, maxLen int) *Builder {
+	if maxLen > MaxCodeLength {
+		maxLen = MaxCodeLength
+	}
+	return &Builder{minLen: minLen, maxLen: maxLen}
+}
+
+// AddFile opens the coupon file at path and adds its codes to the builder.
+func (b *Builder) AddFile(path string) error {
+	file, err := OpenFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	skipped, err := b.add(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d coupon codes in %s with characters an index cannot hold", skipped, path)
+	}
+	log.Printf("Coupon base loaded from path: %s", path)
+	return nil
+}
+
+// Add reads codes from r, one per line, and merges them into the builder as a
+// single coupon file. Codes of the kept lengths with characters other than
+// ASCII letters, digits and underscores cannot be indexed; they are skipped
+// and logged.
+func (b *Builder) Add(r io.Reader) error {
+	skipped, err := b.add(r)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d coupon codes with characters an index cannot hold", skipped)
+	}
+	return nil
+}
+
+// add merges the codes read from r and returns how many it had to skip.
+func (b *Builder) add(r io.Reader) (skipped int, err error) {
+	if b.files == math.MaxUint8 {
+		return 0, fmt.Errorf("too many coupon files (max %d)", math.MaxUint8)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var keys []uint64
+	compactAt := compactEvery
+	for scanner.Scan() {
+		code := bytes.TrimSpace(scanner.Bytes())
+		if len(code) < b.minLen || len(code) > b.maxLen {
+			continue
+		}
+		key, ok := encode(code)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		keys = append(keys, key)
+		if len(keys) == compactAt {
+			keys = compact(keys)
+			compactAt = max(compactEvery, 2*len(keys))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return skipped, err
+	}
+
+	b.merge(compact(keys))
+	b.files++
+	return skipped, nil
+}
+
+// compact sorts keys and removes duplicates.
+func compact(keys []uint64) []uint64 {
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// merge folds a sorted, unique key array from one file into the builder.
+func (b *Builder) merge(keys []uint64) {
+	if len(b.keys) == 0 {
+		b.keys = keys
+		b.counts = make([]uint8, len(keys))
+		for i := range b.counts {
+			b.counts[i] = 1
+		}
+		return
+	}
+
+	merged := make([]uint64, 0, len(b.keys)+len(keys))
+	counts := make([]uint8, 0, cap(merged))
+
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(keys) {
+		switch {
+		case j == len(keys) || (i < len(b.keys) && b.keys[i] < keys[j]):
+			merged = append(merged, b.keys[i])
+			counts = append(counts, b.counts[i])
+			i++
+		case i == len(b.keys) || keys[j] < b.keys[i]:
+			merged = append(merged, keys[j])
+			counts = append(counts, 1)
+			j++
+		default:
+			merged = append(merged, b.keys[i])
+			counts = append(counts, b.counts[i]+1)
+			i++
+			j++
+		}
+	}
+
+	b.keys = slices.Clip(merged)
+	b.counts = slices.Clip(counts)
+}
+
+// Index returns the index built so far. The builder must not be used
+// afterwards.
+func (b *Builder) Index() *Index {
+	idx := &Index{
+		keys:   b.keys,
+		counts: b.counts,
+		files:  b.files,
+		minLen: b.minLen,
+		maxLen: b.maxLen,
+	}
+	b.keys, b.counts = nil, nil
+	return idx
+}
+
+// LoadFiles builds an index from the given coupon files, keeping codes whose
+// length lies within [minLen, maxLen].
+func LoadFiles(minLen, maxLen int, paths ...string) (*Index, error) {
+	b := NewBuilder(minLen, maxLen)
+	for _, path := range paths {
+		if err := b.AddFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return b.Index(), nil
+}
+
+// gzipReadCloser closes both the gzip stream and the underlying file.
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipReadCloser) Close() error {
+	gzErr := g.Reader.Close()
+	if err := g.file.Close(); err != nil {
+		return err
+	}
+	return gzErr
+}
+
+// OpenFile opens a coupon base for reading. Gzip-compressed files are
+// detected by their magic header and decompressed on the fly, so plain text
+// and .gz files can be used interchangeably.
+func OpenFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(file)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error opening gzip stream %s: %w", path, err)
+		}
+		return &gzipReadCloser{Reader: gz, file: file}, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{br, file}, nil
+}
@@ -0,0 +1,164 @@
+package coupon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// On-disk index layout (little endian):
+//
+//	offset  size  field
+//	0       8     magic "CPNINDEX"
+//	8       4     format version
+//	12      1     minimum code length
+//	13      1     maximum code length
+//	14      1     number of source files
+//	15      1     reserved
+//	16      8     number of codes (n)
+//	24      8     reserved
+//	32      8*n   sorted packed keys
+//	32+8*n  n     per-key file counts
+//
+// The header is a multiple of 8 bytes so the key array stays aligned when the
+// file is memory-mapped.
+const (
+	indexMagic      = "CPNINDEX"
+	IndexVersion    = 1
+	indexHeaderSize = 32
+)
+
+// ErrInvalidIndex is returned when an index file is malformed or was written
+// by an incompatible version of the builder.
+var ErrInvalidIndex = errors.New("invalid coupon index file")
+
+// WriteFile writes the index to path in the binary index format. The file is
+// written to a temporary sibling first and renamed into place, so a running
+// server never observes a partially written index.
+func (idx *Index) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error creating index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	if err := idx.write(w); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing index file: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing index file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting index file mode: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing index file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (idx *Index) write(w io.Writer) error {
+	var header [indexHeaderSize]byte
+	copy(header[:8], indexMagic)
+	binary.LittleEndian.PutUint32(header[8:12], IndexVersion)
+	header[12] = uint8(idx.minLen)
+	header[13] = uint8(idx.maxLen)
+	header[14] = uint8(idx.files)
+	binary.LittleEndian.PutUint64(header[16:24], uint64(len(idx.keys)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	var buf [8]byte
+	for _, key := range idx.keys {
+		binary.LittleEndian.PutUint64(buf[:], key)
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(idx.counts)
+	return err
+}
+
+// OpenIndex memory-maps a prebuilt index file. The returned index references
+// the mapping directly, so opening is constant time regardless of index size;
+// call Close to release the mapping.
+func OpenIndex(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading index file: %w", err)
+	}
+	if info.Size() < indexHeaderSize {
+		return nil, fmt.Errorf("%w: %s is too short", ErrInvalidIndex, path)
+	}
+
+	data, unmap, err := mapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("error mapping index file: %w", err)
+	}
+
+	idx, err := parseIndex(data)
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidIndex, path, err)
+	}
+	idx.closer = unmap
+	return idx, nil
+}
+
+func parseIndex(data []byte) (*Index, error) {
+	if string(data[:8]) != indexMagic {
+		return nil, errors.New("bad magic")
+	}
+	if version := binary.LittleEndian.Uint32(data[8:12]); version != IndexVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	n := binary.LittleEndian.Uint64(data[16:24])
+	if want := uint64(indexHeaderSize) + n*9; uint64(len(data)) != want {
+		return nil, fmt.Errorf("size %d does not match %d codes", len(data), n)
+	}
+
+	idx := &Index{
+		minLen: int(data[12]),
+		maxLen: int(data[13]),
+		files:  int(data[14]),
+	}
+	if n == 0 {
+		return idx, nil
+	}
+
+	keyBytes := data[indexHeaderSize : indexHeaderSize+8*n]
+	if littleEndian {
+		idx.keys = unsafe.Slice((*uint64)(unsafe.Pointer(&keyBytes[0])), n)
+	} else {
+		idx.keys = make([]uint64, n)
+		for i := range idx.keys {
+			idx.keys[i] = binary.LittleEndian.Uint64(keyBytes[8*i:])
+		}
+	}
+	idx.counts = data[indexHeaderSize+8*n:]
+	return idx, nil
+}
+
+var littleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
@@ -0,0 +1,110 @@
+package coupon
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestIndex(t *testing.T, files ...string) string {
+	t.Helper()
+	b := NewBuilder(8, 10)
+	for _, file := range files {
+		if err := b.Add(strings.NewReader(file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "coupons.idx")
+	if err := b.Index().WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIndexFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  map[string]int
+	}{
+		{
+			name:  "codes",
+			files: []string{"ABCDEFGH\nXYZ12345\n", "ABCDEFGH\nzz_zz_zz_z\n"},
+			want:  map[string]int{"ABCDEFGH": 2, "XYZ12345": 1, "zz_zz_zz_z": 1, "MISSING1": 0},
+		},
+		{
+			name:  "no codes",
+			files: []string{"SHORT\n"},
+			want:  map[string]int{"SHORT": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx, err := OpenIndex(writeTestIndex(t, tt.files...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+
+			if idx.Files() != len(tt.files) {
+				t.Errorf("Files() = %d, want %d", idx.Files(), len(tt.files))
+			}
+			if !idx.Covers(8, 10) {
+				t.Errorf("index does not cover lengths 8-10")
+			}
+			for code, want := range tt.want {
+				if got := idx.Count(code); got != want {
+					t.Errorf("Count(%q) = %d, want %d", code, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenIndexRejectsCorruptFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{"empty", func([]byte) []byte { return nil }},
+		{"short header", func(data []byte) []byte { return data[:indexHeaderSize-1] }},
+		{"bad magic", func(data []byte) []byte {
+			copy(data, "NOTINDEX")
+			return data
+		}},
+		{"other version", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[8:12], IndexVersion+1)
+			return data
+		}},
+		{"code count too high", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[16:24], 3)
+			return data
+		}},
+		{"truncated counts", func(data []byte) []byte { return data[:len(data)-1] }},
+		{"trailing data", func(data []byte) []byte { return append(data, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestIndex(t, "ABCDEFGH\nXYZ12345\n")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.corrupt(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			idx, err := OpenIndex(path)
+			if err == nil {
+				idx.Close()
+				t.Fatal("OpenIndex succeeded")
+			}
+			if !errors.Is(err, ErrInvalidIndex) {
+				t.Errorf("OpenIndex error = %v, want ErrInvalidIndex", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package coupon
+
+import (
+	"sort"
+)
+
+const (
+	// MinCodeLength and MaxCodeLength bound the codes kept in an index.
+	// MaxCodeLength is also the longest code that fits in a packed key.
+	MinCodeLength = 8
+	MaxCodeLength = 10
+
+	symbolBits = 6
+	symbolMask = 1<<symbolBits - 1
+)
+
+// alphabet lists the characters a packed key can hold. Symbol 0 is reserved
+// as padding, so alphabet[i] is encoded as i+1.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_"
+
+var symbols [256]uint8
+
+func init() {
+	for i := 0; i < len(alphabet); i++ {
+		symbols[alphabet[i]] = uint8(i + 1)
+	}
+}
+
+// encode packs code into a uint64 using 6 bits per character, left aligned so
+// that keys sort in the order of the alphabet, each code before the longer
+// codes it prefixes. That is byte order except for '_', which sorts last.
+func encode[T string | []byte](code T) (uint64, bool) {
+	if len(code) == 0 || len(code) > MaxCodeLength {
+		return 0, false
+	}
+
+	var key uint64
+	for i := 0; i < len(code); i++ {
+		sym := symbols[code[i]]
+		if sym == 0 {
+			return 0, false
+		}
+		key = key<<symbolBits | uint64(sym)
+	}
+	return key << (symbolBits * (MaxCodeLength - len(code))), true
+}
+
+// Index answers how many coupon files contain a given code. Codes are stored
+// as a sorted array of packed 8-byte keys with a parallel array of per-code
+// file counts, which keeps the footprint at 9 bytes per distinct code.
+type Index struct {
+	keys   []uint64
+	counts []uint8
+	files  int
+	minLen int
+	maxLen int
+	closer func() error
+}
+
+// Count returns the number of files that contain code.
+func (idx *Index) Count(code string) int {
+	if idx == nil {
+		return 0
+	}
+
+	key, ok := encode(code)
+	if !ok {
+		return 0
+	}
+
+	i := sort.Search(len(idx.keys), func(i int) bool { return idx.keys[i] >= key })
+	if i < len(idx.keys) && idx.keys[i] == key {
+		return int(idx.counts[i])
+	}
+	return 0
+}
+
+// Len returns the number of distinct codes in the index.
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.keys)
+}
+
+// Files returns the number of coupon files the index was built from.
+func (idx *Index) Files() int {
+	if idx == nil {
+		return 0
+	}
+	return idx.files
+}
+
+// Covers reports whether the index kept every code with a length in
+// [minLen, maxLen] when it was built.
+func (idx *Index) Covers(minLen, maxLen int) bool {
+	return idx != nil && idx.minLen <= minLen && idx.maxLen >= maxLen
+}
+
+// Close releases resources held by the index, such as a memory mapping. The
+// index must not be used after Close.
+func (idx *Index) Close() error {
+	if idx == nil || idx.closer == nil {
+		return nil
+	}
+	err := idx.closer()
+	idx.keys, idx.counts, idx.closer = nil, nil, nil
+	return err
+}
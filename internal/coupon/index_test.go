@@ -0,0 +1,136 @@
+package coupon
+
+import (
+	"strings"
+	"testing"
+)
+
+// decode unpacks a key made by encode.
+func decode(key uint64) string {
+	var code []byte
+	for i := MaxCodeLength - 1; i >= 0; i-- {
+		sym := key >> (symbolBits * i) & symbolMask
+		if sym == 0 {
+			break
+		}
+		code = append(code, alphabet[sym-1])
+	}
+	return string(code)
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{"single character", "A", true},
+		{"first symbol", "0", true},
+		{"last symbol", "_", true},
+		{"mixed case", "aBcDeFgH", true},
+		{"longest code", "zzzzzzzzzz", true},
+		{"every symbol position", "09AZaz_09A", true},
+		{"empty", "", false},
+		{"too long", "ABCDEFGHIJK", false},
+		{"hyphen", "SAVE-1234", false},
+		{"space", "SAVE 1234", false},
+		{"non-ASCII", "CAFÉ1234", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := encode(tt.code)
+			if ok != tt.ok {
+				t.Fatalf("encode(%q) ok = %v, want %v", tt.code, ok, tt.ok)
+			}
+			if !ok {
+				if key != 0 {
+					t.Errorf("encode(%q) = %#x, want 0", tt.code, key)
+				}
+				return
+			}
+			if got := decode(key); got != tt.code {
+				t.Errorf("decode(encode(%q)) = %q", tt.code, got)
+			}
+			if bytesKey, _ := encode([]byte(tt.code)); bytesKey != key {
+				t.Errorf("encode([]byte(%q)) = %#x, want %#x", tt.code, bytesKey, key)
+			}
+		})
+	}
+}
+
+func TestEncodeKeepsOrder(t *testing.T) {
+	// In alphabet order, which puts _ after the letters
+	codes := []string{"0", "00", "0000000000", "09", "A", "AB", "ABC", "Z", "a", "z", "zzzzzzzzzz", "_", "_0"}
+
+	var prev uint64
+	for i, code := range codes {
+		key, ok := encode(code)
+		if !ok {
+			t.Fatalf("encode(%q) failed", code)
+		}
+		if i > 0 && key <= prev {
+			t.Errorf("encode(%q) = %#x, not above encode(%q) = %#x", code, key, codes[i-1], prev)
+		}
+		prev = key
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	files := []string{
+		"ABCDEFGH\nABCDEFGH\nSHORT\nABCDEFGHIJK\nSAVE-1234\n  XYZ12345\r\n",
+		"ABCDEFGH\nXYZ12345\nONLYHERE1\n",
+		"ABCDEFGH\n\n",
+	}
+	b := NewBuilder(8, 10)
+	for _, file := range files {
+		if err := b.Add(strings.NewReader(file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := b.Index()
+
+	tests := []struct {
+		code string
+		want int
+	}{
+		{"ABCDEFGH", 3},
+		{"XYZ12345", 2},
+		{"ONLYHERE1", 1},
+		{"SHORT", 0},
+		{"ABCDEFGHIJK", 0},
+		{"SAVE-1234", 0},
+		{"abcdefgh", 0},
+	}
+	for _, tt := range tests {
+		if got := idx.Count(tt.code); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+	if idx.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", idx.Len())
+	}
+	if idx.Files() != len(files) {
+		t.Errorf("Files() = %d, want %d", idx.Files(), len(files))
+	}
+	if !idx.Covers(8, 10) || idx.Covers(7, 10) {
+		t.Errorf("Covers does not match the builder's length range")
+	}
+}
+
+func TestBuilderCompactsLargeFiles(t *testing.T) {
+	var file strings.Builder
+	for i := 0; i < compactEvery+10; i++ {
+		file.WriteString("REPEATED1\n")
+	}
+	file.WriteString("LASTCODE1\n")
+
+	b := NewBuilder(8, 10)
+	if err := b.Add(strings.NewReader(file.String())); err != nil {
+		t.Fatal(err)
+	}
+	idx := b.Index()
+	if idx.Len() != 2 || idx.Count("REPEATED1") != 1 || idx.Count("LASTCODE1") != 1 {
+		t.Errorf("got %d codes, REPEATED1 in %d files and LASTCODE1 in %d, want 2, 1 and 1",
+			idx.Len(), idx.Count("REPEATED1"), idx.Count("LASTCODE1"))
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package coupon
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole file into memory on platforms without mmap.
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,87 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reasons a code can be rejected by a Policy.
+var (
+	ErrCodeLength   = errors.New("code length is out of range")
+	ErrCodeCharset  = errors.New("code contains characters that are not allowed")
+	ErrCodeNotFound = errors.New("code is not present in enough coupon files")
+	ErrCodeTooMany  = errors.New("code is present in too many coupon files")
+	ErrCodeDisabled = errors.New("code has been disabled")
+)
+
+// Policy decides whether a code is valid based on its shape and the number
+// of coupon files it appears in.
+type Policy struct {
+	// MinFiles and MaxFiles bound the number of files that must contain the
+	// code. MaxFiles of 0 means no upper bound.
+	MinFiles int
+	MaxFiles int
+
+	// MinLength and MaxLength bound the code length in characters.
+	MinLength int
+	MaxLength int
+
+	// Charset lists the characters a code may contain. An empty charset
+	// allows every character the index can store.
+	Charset string
+}
+
+// DefaultPolicy returns the promo code rules from the challenge: 8 to 10
+// characters, found in at least two files.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinFiles:  2,
+		MinLength: MinCodeLength,
+		MaxLength: MaxCodeLength,
+	}
+}
+
+// Validate reports whether the policy is internally consistent and can be
+// answered by an index.
+func (p Policy) Validate() error {
+	if p.MinFiles < 1 {
+		return fmt.Errorf("minimum file count must be at least 1, got %d", p.MinFiles)
+	}
+	if p.MaxFiles != 0 && p.MaxFiles < p.MinFiles {
+		return fmt.Errorf("maximum file count %d is below minimum %d", p.MaxFiles, p.MinFiles)
+	}
+	if p.MinLength < 1 || p.MaxLength > MaxCodeLength || p.MinLength > p.MaxLength {
+		return fmt.Errorf("invalid code length bounds %d..%d (max %d)", p.MinLength, p.MaxLength, MaxCodeLength)
+	}
+	for _, c := range p.Charset {
+		if c > 0xff || symbols[c] == 0 {
+			return fmt.Errorf("charset character %q cannot be stored in the coupon index", c)
+		}
+	}
+	return nil
+}
+
+// Check returns nil if code is valid under the policy according to idx, or
+// one of the ErrCode* errors describing why it was rejected.
+func (p Policy) Check(idx *Index, code string) error {
+	if len(code) < p.MinLength || len(code) > p.MaxLength {
+		return ErrCodeLength
+	}
+	if p.Charset != "" {
+		for _, c := range code {
+			if !strings.ContainsRune(p.Charset, c) {
+				return ErrCodeCharset
+			}
+		}
+	}
+
+	n := idx.Count(code)
+	if n < p.MinFiles {
+		return ErrCodeNotFound
+	}
+	if p.MaxFiles != 0 && n > p.MaxFiles {
+		return ErrCodeTooMany
+	}
+	return nil
+}
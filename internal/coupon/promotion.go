@@ -0,0 +1,224 @@
+package coupon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"time"
+)
+
+// DiscountType identifies how a promotion computes its discount.
+type DiscountType string
+
+const (
+	// DiscountPercentage takes Value percent off the subtotal.
+	DiscountPercentage DiscountType = "percentage"
+	// DiscountCappedPercentage takes Value percent off the subtotal, up to
+	// MaxDiscount.
+	DiscountCappedPercentage DiscountType = "capped_percentage"
+	// DiscountFixed takes a fixed amount of Value off the subtotal.
+	DiscountFixed DiscountType = "fixed"
+	// DiscountFreeItem makes Quantity units of ProductID free.
+	DiscountFreeItem DiscountType = "free_item"
+)
+
+var (
+	ErrNoPromotion         = errors.New("no promotion is defined for this code")
+	ErrPromotionNoMatch    = errors.New("basket does not contain the promotion's free item")
+	ErrPromotionNotStarted = errors.New("promotion has not started yet")
+	ErrPromotionEnded      = errors.New("promotion has ended")
+)
+
+// Promotion maps a code, or a glob pattern of codes, to a discount.
+type Promotion struct {
+	// Code is an exact code or a pattern in path.Match syntax, e.g. "HAPPY*".
+	Code        string       `json:"code"`
+	Type        DiscountType `json:"type"`
+	Value       float64      `json:"value,omitempty"`
+	MaxDiscount float64      `json:"maxDiscount,omitempty"`
+	ProductID   string       `json:"productId,omitempty"`
+	Quantity    int          `json:"quantity,omitempty"`
+
+	// MaxRedemptions and MaxPerCustomer limit how often the code can be
+	// used in total and by a single customer; 0 means unlimited.
+	MaxRedemptions int `json:"maxRedemptions,omitempty"`
+	MaxPerCustomer int `json:"maxPerCustomer,omitempty"`
+
+	// StartsAt and EndsAt bound when the code can be redeemed.
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
+// Line is a priced basket line a discount is computed against.
+type Line struct {
+	ProductID string
+	Price     float64
+	Quantity  int
+}
+
+// Validate reports whether the promotion definition is usable.
+func (p Promotion) Validate() error {
+	if p.Code == "" {
+		return errors.New("promotion code is required")
+	}
+	if _, err := path.Match(p.Code, ""); err != nil {
+		return fmt.Errorf("promotion %s: invalid pattern: %w", p.Code, err)
+	}
+
+	switch p.Type {
+	case DiscountPercentage, DiscountCappedPercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			return fmt.Errorf("promotion %s: percentage must be in (0, 100]", p.Code)
+		}
+		if p.Type == DiscountCappedPercentage && p.MaxDiscount <= 0 {
+			return fmt.Errorf("promotion %s: maxDiscount is required", p.Code)
+		}
+	case DiscountFixed:
+		if p.Value <= 0 {
+			return fmt.Errorf("promotion %s: amount must be positive", p.Code)
+		}
+	case DiscountFreeItem:
+		if p.ProductID == "" {
+			return fmt.Errorf("promotion %s: productId is required", p.Code)
+		}
+		if p.Quantity < 0 {
+			return fmt.Errorf("promotion %s: quantity cannot be negative", p.Code)
+		}
+	default:
+		return fmt.Errorf("promotion %s: unknown discount type %q", p.Code, p.Type)
+	}
+
+	if p.MaxRedemptions < 0 || p.MaxPerCustomer < 0 {
+		return fmt.Errorf("promotion %s: redemption limits cannot be negative", p.Code)
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("promotion %s: endsAt must be after startsAt", p.Code)
+	}
+	return nil
+}
+
+// Active reports whether the promotion can be redeemed at now.
+func (p Promotion) Active(now time.Time) error {
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return ErrPromotionNotStarted
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return ErrPromotionEnded
+	}
+	return nil
+}
+
+// Discount returns the amount the promotion takes off a basket with the given
+// subtotal and lines. The discount never exceeds the subtotal.
+func (p Promotion) Discount(subtotal float64, lines []Line) (float64, error) {
+	var discount float64
+	switch p.Type {
+	case DiscountPercentage:
+		discount = subtotal * p.Value / 100
+	case DiscountCappedPercentage:
+		discount = math.Min(subtotal*p.Value/100, p.MaxDiscount)
+	case DiscountFixed:
+		discount = p.Value
+	case DiscountFreeItem:
+		free := p.Quantity
+		if free == 0 {
+			free = 1
+		}
+		matched := false
+		for _, line := range lines {
+			if line.ProductID != p.ProductID || free == 0 {
+				continue
+			}
+			matched = true
+			n := min(free, line.Quantity)
+			discount += line.Price * float64(n)
+			free -= n
+		}
+		if !matched {
+			return 0, ErrPromotionNoMatch
+		}
+	default:
+		return 0, fmt.Errorf("unknown discount type %q", p.Type)
+	}
+
+	return RoundCents(math.Min(discount, subtotal)), nil
+}
+
+// RoundCents rounds a currency amount to two decimal places.
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Promotions is the set of configured promotions. Exact codes take precedence
+// over patterns; patterns are tried in the order they were defined.
+type Promotions struct {
+	exact    map[string]Promotion
+	patterns []Promotion
+}
+
+// NewPromotions validates and indexes a list of promotion definitions.
+func NewPromotions(promos []Promotion) (*Promotions, error) {
+	ps := &Promotions{exact: make(map[string]Promotion)}
+	for _, p := range promos {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		if isPattern(p.Code) {
+			ps.patterns = append(ps.patterns, p)
+			continue
+		}
+		if _, ok := ps.exact[p.Code]; ok {
+			return nil, fmt.Errorf("promotion %s is defined twice", p.Code)
+		}
+		ps.exact[p.Code] = p
+	}
+	return ps, nil
+}
+
+// DefaultPromotions gives every valid code 10% off.
+func DefaultPromotions() *Promotions {
+	return &Promotions{
+		exact:    map[string]Promotion{},
+		patterns: []Promotion{{Code: "*", Type: DiscountPercentage, Value: 10}},
+	}
+}
+
+// LoadPromotions reads a JSON array of promotions from path.
+func LoadPromotions(path string) (*Promotions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var promos []Promotion
+	if err := json.Unmarshal(data, &promos); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return NewPromotions(promos)
+}
+
+// Match returns the promotion that applies to code.
+func (ps *Promotions) Match(code string) (Promotion, bool) {
+	if p, ok := ps.exact[code]; ok {
+		return p, true
+	}
+	for _, p := range ps.patterns {
+		if ok, _ := path.Match(p.Code, code); ok {
+			return p, true
+		}
+	}
+	return Promotion{}, false
+}
+
+func isPattern(code string) bool {
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
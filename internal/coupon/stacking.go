@@ -0,0 +1,105 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Stacking decides how multiple codes on one order combine.
+type Stacking string
+
+const (
+	// StackExclusive allows a single code per order.
+	StackExclusive Stacking = "exclusive"
+	// StackStackable applies every code, each to what the previous ones
+	// left of the subtotal.
+	StackStackable Stacking = "stackable"
+	// StackBestOf applies only the code giving the largest discount.
+	StackBestOf Stacking = "best-of"
+)
+
+var (
+	ErrExclusiveCoupon = errors.New("only one coupon code can be used per order unless stacking is enabled")
+	ErrDuplicateCoupon = errors.New("coupon code listed more than once")
+)
+
+// ParseStacking validates a stacking mode. An empty mode is exclusive.
+func ParseStacking(mode string) (Stacking, error) {
+	switch s := Stacking(mode); s {
+	case "":
+		return StackExclusive, nil
+	case StackExclusive, StackStackable, StackBestOf:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown stacking mode %q", mode)
+	}
+}
+
+// Applied is a discount a promotion contributed to an order.
+type Applied struct {
+	Promotion Promotion
+	Code      string
+	Amount    float64
+}
+
+// Stack combines the promotions for the given codes according to mode.
+// codes and promos are parallel. Codes are evaluated in sorted order so the
+// result does not depend on the order the client listed them in. In best-of
+// mode a code that does not apply to the basket is skipped; it only fails if
+// none applies.
+func Stack(mode Stacking, codes []string, promos []Promotion, subtotal float64, lines []Line) ([]Applied, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	if mode == StackExclusive && len(codes) > 1 {
+		return nil, ErrExclusiveCoupon
+	}
+
+	order := make([]int, len(codes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return codes[order[a]] < codes[order[b]] })
+	for i := 1; i < len(order); i++ {
+		if codes[order[i]] == codes[order[i-1]] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateCoupon, codes[order[i]])
+		}
+	}
+
+	var applied []Applied
+	var skipped error
+	remaining := subtotal
+	for _, i := range order {
+		base := subtotal
+		if mode == StackStackable {
+			base = remaining
+		}
+
+		amount, err := promos[i].Discount(base, lines)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", codes[i], err)
+			if mode == StackBestOf {
+				skipped = errors.Join(skipped, err)
+				continue
+			}
+			return nil, err
+		}
+
+		a := Applied{Promotion: promos[i], Code: codes[i], Amount: amount}
+		switch mode {
+		case StackBestOf:
+			if len(applied) == 0 || amount > applied[0].Amount {
+				applied = []Applied{a}
+			}
+		default:
+			applied = append(applied, a)
+			remaining = RoundCents(remaining - amount)
+		}
+	}
+
+	if len(applied) == 0 && skipped != nil {
+		return nil, skipped
+	}
+	return applied, nil
+}
@@ -0,0 +1,125 @@
+package coupon
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	tenPercent := Promotion{Code: "TENPERCENT", Type: DiscountPercentage, Value: 10}
+	fiveOff := Promotion{Code: "FIVEOFF000", Type: DiscountFixed, Value: 5}
+	freeCake := Promotion{Code: "FREECAKE00", Type: DiscountFreeItem, ProductID: "cake"}
+	lines := []Line{{ProductID: "coffee", Price: 20, Quantity: 2}}
+
+	type result struct {
+		Code   string
+		Amount float64
+	}
+	tests := []struct {
+		name    string
+		mode    Stacking
+		codes   []string
+		promos  []Promotion
+		want    []result
+		wantErr error
+	}{
+		{
+			name: "no codes",
+			mode: StackExclusive,
+		},
+		{
+			name:   "exclusive single code",
+			mode:   StackExclusive,
+			codes:  []string{"TENPERCENT"},
+			promos: []Promotion{tenPercent},
+			want:   []result{{"TENPERCENT", 4}},
+		},
+		{
+			name:    "exclusive rejects several codes",
+			mode:    StackExclusive,
+			codes:   []string{"TENPERCENT", "FIVEOFF000"},
+			promos:  []Promotion{tenPercent, fiveOff},
+			wantErr: ErrExclusiveCoupon,
+		},
+		{
+			name:    "duplicate code",
+			mode:    StackStackable,
+			codes:   []string{"TENPERCENT", "TENPERCENT"},
+			promos:  []Promotion{tenPercent, tenPercent},
+			wantErr: ErrDuplicateCoupon,
+		},
+		{
+			// FIVEOFF000 sorts first: 40 - 5 = 35, then 10% of 35.
+			name:   "stackable applies codes in sorted order",
+			mode:   StackStackable,
+			codes:  []string{"TENPERCENT", "FIVEOFF000"},
+			promos: []Promotion{tenPercent, fiveOff},
+			want:   []result{{"FIVEOFF000", 5}, {"TENPERCENT", 3.5}},
+		},
+		{
+			name:    "stackable fails on a code that does not apply",
+			mode:    StackStackable,
+			codes:   []string{"TENPERCENT", "FREECAKE00"},
+			promos:  []Promotion{tenPercent, freeCake},
+			wantErr: ErrPromotionNoMatch,
+		},
+		{
+			name:   "best-of keeps the largest discount",
+			mode:   StackBestOf,
+			codes:  []string{"TENPERCENT", "FIVEOFF000"},
+			promos: []Promotion{tenPercent, fiveOff},
+			want:   []result{{"FIVEOFF000", 5}},
+		},
+		{
+			name:   "best-of skips a code that does not apply",
+			mode:   StackBestOf,
+			codes:  []string{"FREECAKE00", "TENPERCENT"},
+			promos: []Promotion{freeCake, tenPercent},
+			want:   []result{{"TENPERCENT", 4}},
+		},
+		{
+			name:    "best-of fails when no code applies",
+			mode:    StackBestOf,
+			codes:   []string{"FREECAKE00"},
+			promos:  []Promotion{freeCake},
+			wantErr: ErrPromotionNoMatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := Stack(tt.mode, tt.codes, tt.promos, 40, lines)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Stack error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []result
+			for _, a := range applied {
+				got = append(got, result{a.Code, a.Amount})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stack applied %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStacking(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    Stacking
+		wantErr bool
+	}{
+		{"", StackExclusive, false},
+		{"exclusive", StackExclusive, false},
+		{"stackable", StackStackable, false},
+		{"best-of", StackBestOf, false},
+		{"bestof", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStacking(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStacking(%q) = %q, %v", tt.mode, got, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package coupon
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrReloadInProgress is returned by Reload when another reload is running.
+var ErrReloadInProgress = errors.New("coupon reload already in progress")
+
+// Loader builds a fresh index, e.g. by rescanning the coupon files or
+// re-mapping a prebuilt index file.
+type Loader func() (*Index, error)
+
+// ReloadStatus describes the outcome of the most recent reload.
+type ReloadStatus struct {
+	Reloading  bool       `json:"reloading"`
+	Codes      int        `json:"codes"`
+	Files      int        `json:"files"`
+	Successes  int        `json:"successes"`
+	Failures   int        `json:"failures"`
+	LastReload *time.Time `json:"lastReload,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+// Store holds the active coupon index and swaps in rebuilt indexes without
+// interrupting readers. A reader that acquired an index keeps using it until
+// it releases it, so an order always sees a single consistent index; a
+// replaced index is closed once its last reader is done.
+type Store struct {
+	load      Loader
+	reloading atomic.Bool
+
+	mu      sync.RWMutex
+	current *storeEntry
+	status  ReloadStatus
+}
+
+type storeEntry struct {
+	index   *Index
+	readers sync.WaitGroup
+}
+
+// NewStore returns a store serving idx and using load to rebuild it.
+func NewStore(idx *Index, load Loader) *Store {
+	return &Store{
+		load:    load,
+		current: &storeEntry{index: idx},
+		status:  ReloadStatus{Codes: idx.Len(), Files: idx.Files()},
+	}
+}
+
+// Acquire returns the active index and a function that must be called once
+// the caller is done with it.
+func (s *Store) Acquire() (*Index, func()) {
+	s.mu.RLock()
+	entry := s.current
+	entry.readers.Add(1)
+	s.mu.RUnlock()
+	return entry.index, entry.readers.Done
+}
+
+// Reload rebuilds the index and swaps it in. On failure the active index is
+// kept.
+func (s *Store) Reload() error {
+	if !s.reloading.CompareAndSwap(false, true) {
+		return ErrReloadInProgress
+	}
+	defer s.reloading.Store(false)
+	return s.reload()
+}
+
+// ReloadAsync starts a reload in the background. It returns
+// ErrReloadInProgress if a reload is already running.
+func (s *Store) ReloadAsync() error {
+	if !s.reloading.CompareAndSwap(false, true) {
+		return ErrReloadInProgress
+	}
+	go func() {
+		defer s.reloading.Store(false)
+		s.reload()
+	}()
+	return nil
+}
+
+func (s *Store) reload() error {
+	start := time.Now()
+	idx, err := s.load()
+	duration := time.Since(start).Round(time.Millisecond)
+
+	s.mu.Lock()
+	s.status.LastReload = &start
+	s.status.Duration = duration.String()
+	if err != nil {
+		s.status.Failures++
+		s.status.LastError = err.Error()
+		s.mu.Unlock()
+		log.Printf("Coupon reload failed after %s: %v", duration, err)
+		return err
+	}
+	old := s.current
+	s.current = &storeEntry{index: idx}
+	s.status.Successes++
+	s.status.LastError = ""
+	s.status.Codes = idx.Len()
+	s.status.Files = idx.Files()
+	s.mu.Unlock()
+
+	log.Printf("Coupon reload succeeded in %s: %d codes from %d files", duration, idx.Len(), idx.Files())
+
+	go func() {
+		old.readers.Wait()
+		if err := old.index.Close(); err != nil {
+			log.Printf("Error closing replaced coupon index: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Status returns the outcome of the most recent reload.
+func (s *Store) Status() ReloadStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := s.status
+	status.Reloading = s.reloading.Load()
+	return status
+}
+
+// Close closes the active index. The store must not be used afterwards.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.readers.Wait()
+	return s.current.index.Close()
+}
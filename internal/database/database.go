@@ -1,11 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -19,9 +21,17 @@ func InitDB() error {
 		return fmt.Errorf("error creating data directory: %w", err)
 	}
 
-	// Open SQLite database
+	// Transactions take the write lock up front so that checks made inside
+	// them (e.g. coupon limits) cannot race. Foreign keys are enabled in the
+	// DSN so that every pooled connection enforces them.
 	dbPath := filepath.Join("data", "orders.db")
-	db, err := sqlx.Open("sqlite3", dbPath)
+	return Open(dbPath + "?_busy_timeout=5000&_txlock=immediate&_foreign_keys=1")
+}
+
+// Open connects DB to the SQLite database at dsn and brings its schema up to
+// date.
+func Open(dsn string) error {
+	db, err := sqlx.Open("sqlite3", dsn)
 	if err != nil {
 		return fmt.Errorf("error opening database: %w", err)
 	}
@@ -30,16 +40,13 @@ func InitDB() error {
 		return fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	// Enable foreign keys
-	_, err = db.Exec(`PRAGMA foreign_keys = ON;`)
-	if err != nil {
-		return fmt.Errorf("error enabling foreign keys: %w", err)
-	}
-
 	DB = db
 	log.Println("Connected to SQLite database")
 
-	return createTables()
+	if err := createTables(); err != nil {
+		return err
+	}
+	return migrate()
 }
 
 func createTables() error {
@@ -101,6 +108,446 @@ func createTables() error {
 	return nil
 }
 
+// migrate brings databases created by older versions up to the current
+// schema. Every step must be safe to run repeatedly.
+func migrate() error {
+	if err := addColumn("orders", "customer_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumn("products", "deleted_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if err := addColumn("products", "description", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// A NULL stock means the product's stock is not tracked.
+	if err := addColumn("products", "stock", "INTEGER"); err != nil {
+		return err
+	}
+
+	// Later steps extend these tables, so they are created first.
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS coupon_redemptions (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		coupon_code TEXT NOT NULL,
+		customer_id TEXT,
+		redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_code ON coupon_redemptions(coupon_code, customer_id);
+	CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_order_id ON coupon_redemptions(order_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating coupon redemption tables: %w", err)
+	}
+
+	if err := addColumn("coupon_redemptions", "discount_type", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumn("coupon_redemptions", "discount", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+	CREATE TABLE IF NOT EXISTS promo_codes (
+		code TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		value REAL NOT NULL DEFAULT 0,
+		max_discount REAL NOT NULL DEFAULT 0,
+		product_id TEXT,
+		quantity INTEGER NOT NULL DEFAULT 0,
+		max_redemptions INTEGER NOT NULL DEFAULT 0,
+		max_per_customer INTEGER NOT NULL DEFAULT 0,
+		starts_at TIMESTAMP,
+		ends_at TIMESTAMP,
+		active BOOLEAN NOT NULL DEFAULT 1,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TRIGGER IF NOT EXISTS update_promo_codes_updated_at
+	AFTER UPDATE ON promo_codes
+	BEGIN
+		UPDATE promo_codes SET updated_at = CURRENT_TIMESTAMP WHERE code = NEW.code;
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating promo code tables: %w", err)
+	}
+
+	if err := createProductSearch(); err != nil {
+		return err
+	}
+	if err := createCategories(); err != nil {
+		return err
+	}
+	if err := createModifiers(); err != nil {
+		return err
+	}
+	if err := createAvailability(); err != nil {
+		return err
+	}
+	if err := addOrderItemSnapshots(); err != nil {
+		return err
+	}
+	if err := createOrderStatus(); err != nil {
+		return err
+	}
+	if err := createRefunds(); err != nil {
+		return err
+	}
+	if err := createIdempotencyKeys(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// createProductSearch sets up the products_fts full-text index and the
+// triggers keeping it in sync with products. FTS5 is only compiled into
+// go-sqlite3 with the sqlite_fts5 build tag. Without it search falls back to
+// LIKE matching, but only on a database that has no index yet: the sync
+// triggers would make every product write fail, and they are left for the
+// binaries built with FTS5 that share the database.
+func createProductSearch() error {
+	var fts5 bool
+	if err := DB.Get(&fts5, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`); err != nil {
+		return fmt.Errorf("error inspecting SQLite compile options: %w", err)
+	}
+
+	if !fts5 {
+		var indexed bool
+		err := DB.Get(&indexed, `SELECT COUNT(*) > 0 FROM sqlite_master WHERE name = 'products_fts'`)
+		if err != nil {
+			return fmt.Errorf("error checking for the product search index: %w", err)
+		}
+		if indexed {
+			return errors.New("the database has a full-text product search index, which needs a binary built with -tags sqlite_fts5")
+		}
+		log.Println("SQLite built without FTS5; product search will use LIKE matching")
+		return nil
+	}
+
+	_, err := DB.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+		name, category, description,
+		content = 'products', content_rowid = 'rowid',
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS products_fts_insert AFTER INSERT ON products
+	BEGIN
+		INSERT INTO products_fts (rowid, name, category, description)
+		VALUES (NEW.rowid, NEW.name, NEW.category, NEW.description);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS products_fts_delete AFTER DELETE ON products
+	BEGIN
+		INSERT INTO products_fts (products_fts, rowid, name, category, description)
+		VALUES ('delete', OLD.rowid, OLD.name, OLD.category, OLD.description);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS products_fts_update AFTER UPDATE ON products
+	BEGIN
+		INSERT INTO products_fts (products_fts, rowid, name, category, description)
+		VALUES ('delete', OLD.rowid, OLD.name, OLD.category, OLD.description);
+		INSERT INTO products_fts (rowid, name, category, description)
+		VALUES (NEW.rowid, NEW.name, NEW.category, NEW.description);
+	END;
+
+	INSERT INTO products_fts (products_fts) VALUES ('rebuild');
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating product search index: %w", err)
+	}
+
+	return nil
+}
+
+// createCategories creates the categories table and links products to it.
+// Products still carrying only a free-text category get a category of the
+// same name (matched case-insensitively), created if necessary.
+func createCategories() error {
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS categories (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		description TEXT NOT NULL DEFAULT '',
+		image TEXT NOT NULL DEFAULT '',
+		display_order INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TRIGGER IF NOT EXISTS update_categories_updated_at
+	AFTER UPDATE ON categories
+	BEGIN
+		UPDATE categories SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating categories table: %w", err)
+	}
+
+	if err := addColumn("products", "category_id", "TEXT REFERENCES categories(id)"); err != nil {
+		return err
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id)`); err != nil {
+		return fmt.Errorf("error creating products category index: %w", err)
+	}
+
+	var names []string
+	err = DB.Select(&names, `SELECT DISTINCT trim(category) FROM products WHERE category_id IS NULL`)
+	if err != nil {
+		return fmt.Errorf("error reading product categories: %w", err)
+	}
+
+	for _, name := range names {
+		_, err := DB.Exec(`INSERT INTO categories (id, name) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`,
+			uuid.New().String(), name)
+		if err != nil {
+			return fmt.Errorf("error creating category %q: %w", name, err)
+		}
+	}
+
+	_, err = DB.Exec(`
+	UPDATE products SET
+		category_id = (SELECT id FROM categories WHERE name = trim(products.category)),
+		category = (SELECT name FROM categories WHERE name = trim(products.category))
+	WHERE category_id IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("error linking products to categories: %w", err)
+	}
+
+	return nil
+}
+
+// createModifiers creates the product modifier tables and the per-order-item
+// copy of the modifiers a customer chose.
+func createModifiers() error {
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS modifier_groups (
+		id TEXT PRIMARY KEY,
+		product_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		required BOOLEAN NOT NULL DEFAULT 0,
+		min_select INTEGER NOT NULL DEFAULT 0,
+		max_select INTEGER NOT NULL DEFAULT 0,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS modifiers (
+		id TEXT PRIMARY KEY,
+		group_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		price_delta REAL NOT NULL DEFAULT 0,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (group_id) REFERENCES modifier_groups(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS order_item_modifiers (
+		id TEXT PRIMARY KEY,
+		order_item_id TEXT NOT NULL,
+		modifier_id TEXT NOT NULL,
+		group_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		price_delta REAL NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_modifier_groups_product_id ON modifier_groups(product_id);
+	CREATE INDEX IF NOT EXISTS idx_modifiers_group_id ON modifiers(group_id);
+	CREATE INDEX IF NOT EXISTS idx_order_item_modifiers_item_id ON order_item_modifiers(order_item_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating modifier tables: %w", err)
+	}
+
+	// Foreign keys used to be enabled on one pooled connection only, so
+	// replacing a product's groups could leave their modifiers behind.
+	_, err = DB.Exec(`DELETE FROM modifiers WHERE group_id NOT IN (SELECT id FROM modifier_groups)`)
+	if err != nil {
+		return fmt.Errorf("error removing orphaned modifiers: %w", err)
+	}
+	return nil
+}
+
+// createAvailability creates the table holding the weekly availability slots
+// of products and categories, one row per window and day. Days are numbered
+// from Sunday (0) and times are minutes since midnight.
+func createAvailability() error {
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS availability_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id TEXT,
+		category_id TEXT,
+		window_index INTEGER NOT NULL,
+		day INTEGER NOT NULL,
+		start_minute INTEGER NOT NULL,
+		end_minute INTEGER NOT NULL,
+		FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+		CHECK ((product_id IS NULL) != (category_id IS NULL))
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_availability_windows_product_id ON availability_windows(product_id, day);
+	CREATE INDEX IF NOT EXISTS idx_availability_windows_category_id ON availability_windows(category_id, day);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating availability table: %w", err)
+	}
+
+	// Windows of categories deleted while foreign keys were not enforced.
+	_, err = DB.Exec(`
+	DELETE FROM availability_windows
+	WHERE category_id IS NOT NULL AND category_id NOT IN (SELECT id FROM categories)
+	`)
+	if err != nil {
+		return fmt.Errorf("error removing orphaned availability windows: %w", err)
+	}
+	return nil
+}
+
+// addOrderItemSnapshots adds the copy of a product's details that order
+// items keep. Items stored before have no copy and keep NULLs: their
+// products may have changed since, so copying today's details would
+// misrepresent the orders.
+func addOrderItemSnapshots() error {
+	columns := []struct{ name, definition string }{
+		{"product_name", "TEXT"},
+		{"product_price", "REAL"},
+		{"category", "TEXT"},
+		{"image_thumbnail", "TEXT"},
+		{"image_mobile", "TEXT"},
+		{"image_tablet", "TEXT"},
+		{"image_desktop", "TEXT"},
+	}
+	for _, column := range columns {
+		if err := addColumn("order_items", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createOrderStatus adds the order status and the history of its changes.
+// Orders stored before have no history; they get a pending entry dated from
+// their creation.
+func createOrderStatus() error {
+	if err := addColumn("orders", "status", "TEXT NOT NULL DEFAULT 'pending'"); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS order_status_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id TEXT NOT NULL,
+		from_status TEXT,
+		to_status TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id);
+	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+
+	INSERT INTO order_status_history (order_id, to_status, changed_at)
+	SELECT id, status, created_at FROM orders
+	WHERE NOT EXISTS (SELECT 1 FROM order_status_history WHERE order_id = orders.id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating order status history: %w", err)
+	}
+	return nil
+}
+
+// createRefunds creates the tables recording the money returned on orders,
+// with the order lines and quantities each refund covers, and lets coupon
+// redemptions be released so that a cancelled order no longer counts against
+// the coupon's limits.
+func createRefunds() error {
+	if err := addColumn("coupon_redemptions", "released_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS refunds (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		amount REAL NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		actor TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS refund_items (
+		id TEXT PRIMARY KEY,
+		refund_id TEXT NOT NULL,
+		order_item_id TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		amount REAL NOT NULL,
+		FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE,
+		FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+	CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items(refund_id);
+	CREATE INDEX IF NOT EXISTS idx_refund_items_order_item_id ON refund_items(order_item_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating refund tables: %w", err)
+	}
+	return nil
+}
+
+// createIdempotencyKeys creates the table remembering the responses to
+// requests sent with an Idempotency-Key. A NULL status_code marks a request
+// that is still in progress.
+func createIdempotencyKeys() error {
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		status_code INTEGER,
+		content_type TEXT NOT NULL DEFAULT '',
+		response BLOB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating idempotency key table: %w", err)
+	}
+	return nil
+}
+
+// addColumn adds a column to table unless it already exists.
+func addColumn(table, column, definition string) error {
+	var exists bool
+	err := DB.Get(&exists, `SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil {
+		return fmt.Errorf("error inspecting table %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = DB.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	if err != nil {
+		return fmt.Errorf("error adding column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
 func Close() error {
 	if DB != nil {
 		return DB.Close()
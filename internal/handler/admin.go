@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/coupon"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// maxPromoCodeLength bounds codes created through the admin API.
+const maxPromoCodeLength = 64
+
+// requireToken rejects requests that do not carry token as a bearer token.
+func requireToken(token string) mux.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(given, expected) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CouponIndexStatus handles GET /admin/coupon-index
+func (h *Handler) CouponIndexStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.coupons.Status())
+}
+
+// ReloadCouponIndex handles POST /admin/coupon-index/reload. The index is
+// rebuilt in the background; poll GET /admin/coupon-index for the outcome.
+func (h *Handler) ReloadCouponIndex(w http.ResponseWriter, r *http.Request) {
+	if err := h.coupons.ReloadAsync(); err != nil {
+		if errors.Is(err, coupon.ErrReloadInProgress) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error reloading coupon index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(h.coupons.Status())
+}
+
+// ListPromoCodes handles GET /admin/coupon
+func (h *Handler) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	promos, err := h.promoRepo.List()
+	if err != nil {
+		http.Error(w, "Error getting promo codes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promos)
+}
+
+// CreatePromoCode handles POST /admin/coupon
+func (h *Handler) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	promo := model.PromoCode{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&promo); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if promo.Code == "" || len(promo.Code) > maxPromoCodeLength || strings.ContainsAny(promo.Code, " \t\r\n*?[\\") {
+		http.Error(w, fmt.Sprintf("Code is required and must be at most %d characters without spaces or wildcards",
+			maxPromoCodeLength), http.StatusBadRequest)
+		return
+	}
+	if err := promoCodeToPromotion(&promo).Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.promoRepo.Create(&promo)
+	if err != nil {
+		if errors.Is(err, repository.ErrPromoCodeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error creating promo code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetPromoCode handles GET /admin/coupon/{code}
+func (h *Handler) GetPromoCode(w http.ResponseWriter, r *http.Request) {
+	promo, err := h.promoRepo.GetByCode(mux.Vars(r)["code"])
+	if err != nil {
+		http.Error(w, "Error fetching promo code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if promo == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promo)
+}
+
+// DisablePromoCode handles POST /admin/coupon/{code}/disable
+func (h *Handler) DisablePromoCode(w http.ResponseWriter, r *http.Request) {
+	h.setPromoCodeActive(w, r, false)
+}
+
+// EnablePromoCode handles POST /admin/coupon/{code}/enable
+func (h *Handler) EnablePromoCode(w http.ResponseWriter, r *http.Request) {
+	h.setPromoCodeActive(w, r, true)
+}
+
+func (h *Handler) setPromoCodeActive(w http.ResponseWriter, r *http.Request, active bool) {
+	code := mux.Vars(r)["code"]
+	found, err := h.promoRepo.SetActive(code, active)
+	if err != nil {
+		http.Error(w, "Error updating promo code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.GetPromoCode(w, r)
+}
+
+// DeletePromoCode handles DELETE /admin/coupon/{code}
+func (h *Handler) DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	found, err := h.promoRepo.Delete(mux.Vars(r)["code"])
+	if err != nil {
+		http.Error(w, "Error deleting promo code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func promoCodeToPromotion(p *model.PromoCode) coupon.Promotion {
+	return coupon.Promotion{
+		Code:           p.Code,
+		Type:           coupon.DiscountType(p.Type),
+		Value:          p.Value,
+		MaxDiscount:    p.MaxDiscount,
+		ProductID:      p.ProductID,
+		Quantity:       p.Quantity,
+		MaxRedemptions: p.MaxRedemptions,
+		MaxPerCustomer: p.MaxPerCustomer,
+		StartsAt:       p.StartsAt,
+		EndsAt:         p.EndsAt,
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ravip18596/order-food-online/internal/coupon"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// basket is a set of requested items resolved against the catalog, with a
+// snapshot of each item's product.
+type basket struct {
+	items    []model.OrderItem
+	products []model.Product
+	lines    []coupon.Line
+	subtotal float64
+}
+
+// priceBasket validates items and prices them with current catalog prices,
+// including the price deltas of the chosen modifiers. Products outside their
+// availability windows are rejected. On failure it writes the error response
+// and returns nil.
+func (h *Handler) priceBasket(w http.ResponseWriter, items []model.OrderItem) *basket {
+	b := &basket{
+		items:    make([]model.OrderItem, 0, len(items)),
+		products: make([]model.Product, 0, len(items)),
+		lines:    make([]coupon.Line, 0, len(items)),
+	}
+
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			http.Error(w, "Quantity must be greater than 0", http.StatusBadRequest)
+			return nil
+		}
+
+		product, err := h.productRepo.GetByID(item.ProductID)
+		if err != nil {
+			http.Error(w, "Error fetching product: "+err.Error(), http.StatusInternalServerError)
+			return nil
+		}
+
+		if product == nil || product.DeletedAt != nil {
+			http.Error(w, "Product not found: "+item.ProductID, http.StatusNotFound)
+			return nil
+		}
+
+		available, err := h.productRepo.IsAvailable(product.ID, h.now())
+		if err != nil {
+			http.Error(w, "Error checking product availability: "+err.Error(), http.StatusInternalServerError)
+			return nil
+		}
+
+		if !available {
+			http.Error(w, "Validation Exception: product "+product.ID+" is not available at this time", 422)
+			return nil
+		}
+
+		delta, err := priceModifiers(product, item.Modifiers)
+		if err != nil {
+			http.Error(w, "Invalid modifiers for product "+product.ID+": "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		price := product.Price + delta
+		if price < 0 {
+			http.Error(w, "Invalid modifiers for product "+product.ID+": price cannot be negative", http.StatusBadRequest)
+			return nil
+		}
+
+		b.items = append(b.items, model.OrderItem{
+			ProductID: product.ID,
+			Quantity:  item.Quantity,
+			Modifiers: item.Modifiers,
+			UnitPrice: price,
+		})
+		b.products = append(b.products, model.Product{
+			ID:       product.ID,
+			Name:     product.Name,
+			Price:    product.Price,
+			Category: product.Category,
+			Image:    product.Image,
+		})
+		b.lines = append(b.lines, coupon.Line{
+			ProductID: product.ID,
+			Price:     price,
+			Quantity:  item.Quantity,
+		})
+
+		b.subtotal += price * float64(item.Quantity)
+	}
+
+	return b
+}
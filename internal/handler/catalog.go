@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ravip18596/order-food-online/internal/catalog"
+)
+
+// maxCatalogImport bounds the size of an imported catalog.
+const maxCatalogImport = 10 << 20
+
+// ImportProducts handles POST /admin/product/import. The body is a JSON array
+// of products or a CSV file, chosen by the format query parameter or else the
+// Content-Type. With dryRun=true the import is checked but not kept.
+func (h *Handler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = catalog.FormatCSV
+	}
+	format, err := catalog.ParseFormat(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "dryRun must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCatalogImport)
+	records, err := catalog.Decode(r.Body, format)
+	if err != nil {
+		if isTooLarge(err) {
+			http.Error(w, "Import is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := catalog.Import(h.productRepo, records, dryRun)
+	if err != nil {
+		http.Error(w, "Error importing products: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Failed > 0 {
+		w.WriteHeader(422)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// ExportProducts handles GET /product/export?format=csv|json
+func (h *Handler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := catalog.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	products, err := h.productRepo.GetAll()
+	if err != nil {
+		http.Error(w, "Error getting all products: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == catalog.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="products.`+format+`"`)
+	// The response has started, so a failure can only be logged
+	if err := catalog.Encode(w, format, products); err != nil {
+		log.Printf("Error exporting products: %v", err)
+	}
+}
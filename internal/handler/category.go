@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+	"github.com/ravip18596/order-food-online/internal/schedule"
+)
+
+// ListCategories handles GET /category
+func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryRepo.List()
+	if err != nil {
+		http.Error(w, "Error getting categories: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// CreateCategory handles POST /category
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var category model.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		http.Error(w, "Name is a required field", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := schedule.Parse(category.Availability); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.categoryRepo.Create(&category)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error creating category: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetCategory handles GET /category/{categoryId}
+func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := h.categoryRepo.GetByID(mux.Vars(r)["categoryId"])
+	if err != nil {
+		http.Error(w, "Error fetching category: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if category == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory handles PUT /category/{categoryId}. Renaming a category
+// renames it on all of its products.
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	var category model.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		http.Error(w, "Name is a required field", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := schedule.Parse(category.Availability); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category.ID = mux.Vars(r)["categoryId"]
+	updated, err := h.categoryRepo.Update(&category)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating category: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if updated == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteCategory handles DELETE /category/{categoryId}
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	found, err := h.categoryRepo.Delete(mux.Vars(r)["categoryId"])
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryInUse) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error deleting category: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ravip18596/order-food-online/internal/coupon"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// ValidateCoupon handles POST /coupon/validate. It reports whether a code
+// would be accepted by PlaceOrder and, when a basket is given, the discount
+// it would apply. Nothing is redeemed.
+func (h *Handler) ValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	var req model.CouponValidationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Code == "" {
+		http.Error(w, "Coupon code is required", http.StatusBadRequest)
+		return
+	}
+
+	b := &basket{}
+	if len(req.Items) > 0 {
+		if b = h.priceBasket(w, req.Items); b == nil {
+			return
+		}
+	}
+
+	result := model.CouponValidation{Code: req.Code}
+
+	promo, err := h.findPromotion(req.Code)
+	if errors.Is(err, errPromoLookup) {
+		http.Error(w, "Error validating coupon: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var discount float64
+	if err == nil && len(req.Items) > 0 {
+		discount, err = promo.Discount(b.subtotal, b.lines)
+	}
+	if err == nil {
+		customerID, verified := h.customerID(r, req.CustomerID)
+		if !verified {
+			customerID = ""
+		}
+		err = h.checkCouponUsage(req.Code, customerID, repository.CouponRedemption{
+			MaxRedemptions: promo.MaxRedemptions,
+			MaxPerCustomer: promo.MaxPerCustomer,
+		})
+		if err != nil && !isCouponLimitError(err) {
+			http.Error(w, "Error checking coupon usage: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err != nil {
+		result.Reason = err.Error()
+	} else {
+		result.Valid = true
+		// Rounded as POST /order rounds them, so the preview matches the order
+		result.Discount = discount
+		result.Subtotal = coupon.RoundCents(b.subtotal)
+		result.Total = coupon.RoundCents(b.subtotal - discount)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// checkCouponUsage previews the redemption limits that Create enforces.
+func (h *Handler) checkCouponUsage(code, customerID string, limits repository.CouponRedemption) error {
+	if limits.MaxRedemptions == 0 && limits.MaxPerCustomer == 0 {
+		return nil
+	}
+	if limits.MaxPerCustomer > 0 && customerID == "" {
+		return repository.ErrCouponCustomerRequired
+	}
+
+	total, customer, err := h.orderRepo.CouponUsage(code, customerID)
+	if err != nil {
+		return err
+	}
+	if limits.MaxRedemptions > 0 && total >= limits.MaxRedemptions {
+		return repository.ErrCouponExhausted
+	}
+	if limits.MaxPerCustomer > 0 && customer >= limits.MaxPerCustomer {
+		return repository.ErrCouponCustomerLimit
+	}
+	return nil
+}
@@ -2,30 +2,76 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/catalog"
+	"github.com/ravip18596/order-food-online/internal/coupon"
+	"github.com/ravip18596/order-food-online/internal/images"
 	"github.com/ravip18596/order-food-online/internal/model"
 	"github.com/ravip18596/order-food-online/internal/repository"
 )
 
 type Handler struct {
-	productRepo *repository.ProductRepository
-	orderRepo   *repository.OrderRepository
-	set         map[string][]int
+	productRepo     *repository.ProductRepository
+	categoryRepo    *repository.CategoryRepository
+	orderRepo       *repository.OrderRepository
+	promoRepo       *repository.PromoCodeRepository
+	idempotencyRepo *repository.IdempotencyRepository
+	coupons         *coupon.Store
+	policy          coupon.Policy
+	promotions      *coupon.Promotions
+	stacking        coupon.Stacking
+	customerHeader  string
+	location        *time.Location
+	imageStore      *images.Store
 }
 
-func NewHandler(productRepo *repository.ProductRepository, orderRepo *repository.OrderRepository,
-	set map[string][]int) *Handler {
+func NewHandler(productRepo *repository.ProductRepository, categoryRepo *repository.CategoryRepository,
+	orderRepo *repository.OrderRepository, promoRepo *repository.PromoCodeRepository,
+	idempotencyRepo *repository.IdempotencyRepository, coupons *coupon.Store, policy coupon.Policy,
+	promotions *coupon.Promotions, stacking coupon.Stacking, customerHeader string, location *time.Location,
+	imageStore *images.Store) *Handler {
 	return &Handler{
-		productRepo: productRepo,
-		orderRepo:   orderRepo,
-		set:         set,
+		productRepo:     productRepo,
+		categoryRepo:    categoryRepo,
+		orderRepo:       orderRepo,
+		promoRepo:       promoRepo,
+		idempotencyRepo: idempotencyRepo,
+		coupons:         coupons,
+		policy:          policy,
+		promotions:      promotions,
+		stacking:        stacking,
+		customerHeader:  customerHeader,
+		location:        location,
+		imageStore:      imageStore,
 	}
 }
 
+// customerID returns the customer a request is made for and whether that
+// customer is verified. With a customer header configured, the ID is read
+// from it; an authenticating proxy in front of the server must set it and
+// drop any value sent by clients. Otherwise the customer is the unverified
+// one claimed in the request body.
+func (h *Handler) customerID(r *http.Request, claimed string) (string, bool) {
+	if h.customerHeader != "" {
+		if id := r.Header.Get(h.customerHeader); id != "" {
+			return id, true
+		}
+	}
+	return claimed, false
+}
+
+// now returns the current time in the store's timezone.
+func (h *Handler) now() time.Time {
+	return time.Now().In(h.location)
+}
+
 func (h *Handler) RegisterRoutes(r *mux.Router) {
 	// Basic health check
 	r.HandleFunc("/health", h.HealthCheck).Methods("GET")
@@ -33,12 +79,58 @@ func (h *Handler) RegisterRoutes(r *mux.Router) {
 	// Product routes
 	r.HandleFunc("/product", h.ListProducts).Methods("GET")
 	r.HandleFunc("/product", h.CreateProduct).Methods("POST")
+	r.HandleFunc("/product/search", h.SearchProducts).Methods("GET")
+	r.HandleFunc("/product/export", h.ExportProducts).Methods("GET")
 	r.HandleFunc("/product/{productId}", h.GetProduct).Methods("GET")
+	r.HandleFunc("/product/{productId}", h.UpdateProduct).Methods("PUT")
+	r.HandleFunc("/product/{productId}", h.PatchProduct).Methods("PATCH")
+	r.HandleFunc("/product/{productId}", h.DeleteProduct).Methods("DELETE")
+	r.HandleFunc("/product/{productId}/image", h.UploadProductImage).Methods("POST")
+	r.PathPrefix(images.URLPrefix).Handler(h.imageStore.Handler()).Methods("GET", "HEAD")
+
+	// Category routes
+	r.HandleFunc("/category", h.ListCategories).Methods("GET")
+	r.HandleFunc("/category", h.CreateCategory).Methods("POST")
+	r.HandleFunc("/category/{categoryId}", h.GetCategory).Methods("GET")
+	r.HandleFunc("/category/{categoryId}", h.UpdateCategory).Methods("PUT")
+	r.HandleFunc("/category/{categoryId}", h.DeleteCategory).Methods("DELETE")
 
 	// Order routes
-	r.HandleFunc("/order", h.PlaceOrder).Methods("POST")
+	r.HandleFunc("/order", h.idempotent(h.PlaceOrder)).Methods("POST")
 	r.HandleFunc("/order", h.ListOrders).Methods("GET")
 	r.HandleFunc("/order/{orderId}", h.GetOrder).Methods("GET")
+	r.HandleFunc("/order/{orderId}/status", h.GetOrderStatusHistory).Methods("GET")
+
+	// Coupon routes
+	r.HandleFunc("/coupon/validate", h.ValidateCoupon).Methods("POST")
+}
+
+// RegisterAdminRoutes registers the admin API, for promo codes, coupon index
+// reloads, product imports, stock levels and order status changes and
+// refunds, under /admin. Every request must carry token as a bearer token in
+// its Authorization header.
+func (h *Handler) RegisterAdminRoutes(r *mux.Router, token string) {
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(requireToken(token))
+
+	admin.HandleFunc("/coupon-index", h.CouponIndexStatus).Methods("GET")
+	admin.HandleFunc("/coupon-index/reload", h.ReloadCouponIndex).Methods("POST")
+
+	admin.HandleFunc("/coupon", h.ListPromoCodes).Methods("GET")
+	admin.HandleFunc("/coupon", h.CreatePromoCode).Methods("POST")
+	admin.HandleFunc("/coupon/{code}", h.GetPromoCode).Methods("GET")
+	admin.HandleFunc("/coupon/{code}", h.DeletePromoCode).Methods("DELETE")
+	admin.HandleFunc("/coupon/{code}/disable", h.DisablePromoCode).Methods("POST")
+	admin.HandleFunc("/coupon/{code}/enable", h.EnablePromoCode).Methods("POST")
+
+	admin.HandleFunc("/product/import", h.ImportProducts).Methods("POST")
+	admin.HandleFunc("/product/{productId}/stock", h.SetStock).Methods("PUT")
+	admin.HandleFunc("/product/{productId}/stock", h.AdjustStock).Methods("PATCH")
+	admin.HandleFunc("/product/{productId}/restock", h.RestockProduct).Methods("POST")
+
+	admin.HandleFunc("/order/{orderId}/status", h.UpdateOrderStatus).Methods("PATCH")
+	admin.HandleFunc("/order/{orderId}/cancel", h.CancelOrder).Methods("POST")
+	admin.HandleFunc("/order/{orderId}/refund", h.RefundOrder).Methods("POST")
 }
 
 // CreateProduct handles POST /product
@@ -49,13 +141,21 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if product.Name == "" || product.Price <= 0 || product.Category == "" {
-		http.Error(w, "Name, price, and category are required fields", http.StatusBadRequest)
+	if err := catalog.Validate(&product); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	createdProduct, err := h.productRepo.Create(&product)
 	if err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrModifierExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Error creating product: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -65,13 +165,35 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdProduct)
 }
 
+// ListProducts handles GET /product. The body stays a plain array; paging
+// metadata is returned in the X-Total-Count, X-Next-Cursor and Link headers.
+// Products that cannot be ordered now are left out unless includeUnavailable
+// is set.
 func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
-	products, err := h.productRepo.GetAll()
+	filter, err := parseProductFilter(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if filter.AvailableAt, err = h.availableAt(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.productRepo.List(filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Error getting all products: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	setPageHeaders(w, r, page.Total, page.NextCursor)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(page.Products)
 }
 
 // GET /product/{productId}
@@ -108,71 +230,83 @@ func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	b := h.priceBasket(w, orderReq.Items)
+	if b == nil {
+		return
+	}
+	total := b.subtotal
+
 	// Prepare order with items
 	order := &model.Order{
 		ID:        uuid.New().String(),
-		Items:     make([]model.OrderItem, 0, len(orderReq.Items)),
-		Products:  make([]model.Product, 0, len(orderReq.Items)),
+		Items:     b.items,
+		Products:  b.products,
 		Discounts: 0,
 	}
 
-	// Calculate total and validate products
-	total := 0.0
-	for _, item := range orderReq.Items {
-		if item.Quantity <= 0 {
-			http.Error(w, "Quantity must be greater than 0", http.StatusBadRequest)
-			return
-		}
+	// Apply coupon codes if provided
+	codes := orderReq.CouponCodes
+	if orderReq.CouponCode != "" {
+		codes = append([]string{orderReq.CouponCode}, codes...)
+	}
 
-		product, err := h.productRepo.GetByID(item.ProductID)
-		if err != nil {
-			http.Error(w, "Error fetching product: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	// Per-customer coupon limits only count verified customers.
+	customerID, verified := h.customerID(r, orderReq.CustomerID)
+	limitedCustomer := ""
+	if verified {
+		limitedCustomer = customerID
+	}
 
-		if product == nil {
-			http.Error(w, "Product not found: "+item.ProductID, http.StatusNotFound)
+	applied, err := h.applyCoupons(codes, limitedCustomer, b)
+	if err != nil {
+		if errors.Is(err, errPromoLookup) {
+			http.Error(w, "Error applying coupons: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		// Add to order items
-		order.Items = append(order.Items, model.OrderItem{
-			ProductID: product.ID,
-			Quantity:  item.Quantity,
-		})
-
-		// Add product details to order
-		order.Products = append(order.Products, *product)
-
-		// Calculate subtotal
-		subtotal := product.Price * float64(item.Quantity)
-		total += subtotal
+		fmt.Println("Coupon codes " + strings.Join(codes, ",") + " are invalid: " + err.Error())
+		http.Error(w, "Validation Exception: "+err.Error(), 422)
+		return
 	}
 
-	// Apply coupon code if provided
-	if orderReq.CouponCode != "" {
-		if len(orderReq.CouponCode) >= 8 && len(orderReq.CouponCode) <= 10 {
-			fileNos, ok := h.set[orderReq.CouponCode]
-			if ok && len(fileNos) == 2 {
-				fmt.Println("Coupon code " + orderReq.CouponCode + " is valid")
-				order.Discounts = total * 0.1
-			} else {
-				fmt.Println("Coupon code " + orderReq.CouponCode + " is invalid")
-				http.Error(w, "Validation Exception", 422)
-				return
-			}
-		} else {
-			fmt.Println("Coupon code " + orderReq.CouponCode + " is invalid")
-			http.Error(w, "Validation Exception", 422)
-			return
-		}
+	var redemptions []repository.CouponRedemption
+	appliedCodes := make([]string, 0, len(applied))
+	for _, a := range applied {
+		fmt.Println("Coupon code " + a.Code + " is valid")
+		order.Discounts += a.Amount
+		order.AppliedDiscounts = append(order.AppliedDiscounts, model.AppliedDiscount{
+			Code:   a.Code,
+			Type:   string(a.Promotion.Type),
+			Amount: a.Amount,
+		})
+		appliedCodes = append(appliedCodes, a.Code)
+		redemptions = append(redemptions, repository.CouponRedemption{
+			Code:           a.Code,
+			CustomerID:     limitedCustomer,
+			DiscountType:   string(a.Promotion.Type),
+			Discount:       a.Amount,
+			MaxRedemptions: a.Promotion.MaxRedemptions,
+			MaxPerCustomer: a.Promotion.MaxPerCustomer,
+			StartsAt:       a.Promotion.StartsAt,
+			EndsAt:         a.Promotion.EndsAt,
+		})
 	}
+	order.Discounts = coupon.RoundCents(order.Discounts)
 
-	order.Total = total - order.Discounts
+	order.CustomerID = customerID
+	order.Total = coupon.RoundCents(total - order.Discounts)
 
 	// Create the order in database
-	createdOrder, err := h.orderRepo.Create(order)
+	createdOrder, err := h.orderRepo.Create(order, redemptions...)
 	if err != nil {
+		if isCouponLimitError(err) {
+			fmt.Println("Coupon codes " + strings.Join(appliedCodes, ",") + " rejected: " + err.Error())
+			http.Error(w, "Validation Exception: "+err.Error(), 422)
+			return
+		}
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Error creating order: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -183,13 +317,110 @@ func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdOrder)
 }
 
+// errPromoLookup marks coupon errors caused by a failed database lookup
+// rather than an invalid code.
+var errPromoLookup = errors.New("promo code lookup failed")
+
+// applyCoupons validates the codes and combines their discounts on the basket
+// according to the server's stacking mode. Every code must be valid, except
+// in best-of mode where invalid codes, including ones that reached their
+// redemption limits, are skipped as long as one is valid.
+func (h *Handler) applyCoupons(codes []string, customerID string, b *basket) ([]coupon.Applied, error) {
+	var valid []string
+	var promos []coupon.Promotion
+	var skipped error
+	for _, code := range codes {
+		promo, err := h.findPromotion(code)
+		if err == nil && h.stacking == coupon.StackBestOf {
+			err = h.checkCouponUsage(code, customerID, repository.CouponRedemption{
+				MaxRedemptions: promo.MaxRedemptions,
+				MaxPerCustomer: promo.MaxPerCustomer,
+			})
+			if err != nil && !isCouponLimitError(err) {
+				err = fmt.Errorf("%w: %v", errPromoLookup, err)
+			}
+		}
+		if err != nil {
+			if h.stacking == coupon.StackBestOf && !errors.Is(err, errPromoLookup) {
+				skipped = errors.Join(skipped, fmt.Errorf("%s: %w", code, err))
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", code, err)
+		}
+		valid = append(valid, code)
+		promos = append(promos, promo)
+	}
+	if len(valid) == 0 && skipped != nil {
+		return nil, skipped
+	}
+	return coupon.Stack(h.stacking, valid, promos, b.subtotal, b.lines)
+}
+
+// findPromotion returns the active promotion for code. Promo codes created
+// through the admin API take precedence; any other code must pass the coupon
+// policy and match a configured promotion.
+func (h *Handler) findPromotion(code string) (coupon.Promotion, error) {
+	promoCode, err := h.promoRepo.GetByCode(code)
+	if err != nil {
+		return coupon.Promotion{}, fmt.Errorf("%w: %v", errPromoLookup, err)
+	}
+	if promoCode != nil {
+		if !promoCode.Active {
+			return coupon.Promotion{}, coupon.ErrCodeDisabled
+		}
+		promo := promoCodeToPromotion(promoCode)
+		if err := promo.Active(time.Now()); err != nil {
+			return coupon.Promotion{}, err
+		}
+		return promo, nil
+	}
+
+	idx, release := h.coupons.Acquire()
+	defer release()
+
+	if err := h.policy.Check(idx, code); err != nil {
+		return coupon.Promotion{}, err
+	}
+
+	promo, ok := h.promotions.Match(code)
+	if !ok {
+		return coupon.Promotion{}, coupon.ErrNoPromotion
+	}
+	if err := promo.Active(time.Now()); err != nil {
+		return coupon.Promotion{}, err
+	}
+	return promo, nil
+}
+
+// isCouponLimitError reports whether err is a coupon limit violation detected
+// while storing an order.
+func isCouponLimitError(err error) bool {
+	return errors.Is(err, repository.ErrCouponNotActive) ||
+		errors.Is(err, repository.ErrCouponExhausted) ||
+		errors.Is(err, repository.ErrCouponCustomerLimit) ||
+		errors.Is(err, repository.ErrCouponCustomerRequired)
+}
+
 func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := h.orderRepo.List()
+	filter, err := h.parseOrderFilter(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.orderRepo.List(filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrUnknownStatus) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Error getting all orders: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	setPageHeaders(w, r, page.Total, page.NextCursor)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	json.NewEncoder(w).Encode(page.Orders)
 }
 
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
@@ -198,6 +429,11 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	order, err := h.orderRepo.GetByID(orderId)
 	if err != nil {
 		http.Error(w, "Error getting order: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if order == nil {
+		http.NotFound(w, r)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(order)
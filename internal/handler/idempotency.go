@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBody bounds the body of a request sent with an Idempotency-Key,
+// which is read into memory to fingerprint it.
+const maxIdempotentBody = 1 << 20
+
+// idempotent makes next safe to retry with an Idempotency-Key header. The
+// first request with a key runs next and its response is stored; later
+// requests with the key and the same body get that response back, marked
+// with an Idempotent-Replayed header, and ones with another body fail with
+// 422. Only successes and validation failures (400 and 422), which a retry
+// would meet again, are stored; after any other response, such as a server
+// error or a 409 conflict, the key is released so the request can be retried.
+// Requests without the header run next as usual.
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			if isTooLarge(err) {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := h.idempotencyRepo.Begin(key, requestFingerprint(r, body))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrIdempotencyKeyReused):
+				http.Error(w, "Validation Exception: "+err.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(err, repository.ErrIdempotencyKeyInProgress):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Error checking idempotency key: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if stored != nil {
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		// The claim is released if next panics or fails, so a retry runs again.
+		capture := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			var err error
+			if stored != nil {
+				err = h.idempotencyRepo.Complete(key, *stored)
+			} else {
+				err = h.idempotencyRepo.Release(key)
+			}
+			if err != nil {
+				log.Printf("Idempotency key %q: %v", key, err)
+			}
+		}()
+
+		next(capture, r)
+
+		if storable(capture.status) {
+			stored = &repository.IdempotentResponse{
+				StatusCode:  capture.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        capture.body.Bytes(),
+			}
+		}
+	}
+}
+
+// storable reports whether a response with the given status is kept for
+// retries.
+func storable(status int) bool {
+	switch {
+	case status >= 200 && status < 300:
+		return true
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return true
+	default:
+		return false
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body. JSON
+// bodies are compared by content, regardless of formatting and key order.
+func requestFingerprint(r *http.Request, body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		body, _ = json.Marshal(value)
+	}
+
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseCapture passes a response through while keeping a copy of its
+// status and body.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(data []byte) (int, error) {
+	c.body.Write(data)
+	return c.ResponseWriter.Write(data)
+}
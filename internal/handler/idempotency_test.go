@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravip18596/order-food-online/internal/database"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+func newIdempotentHandler(t *testing.T) *Handler {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	err := database.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_txlock=immediate&_foreign_keys=1", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.DB
+	t.Cleanup(func() { db.Close() })
+	return &Handler{idempotencyRepo: repository.NewIdempotencyRepository(db, time.Hour)}
+}
+
+func TestIdempotentStoresResponses(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantStored bool
+	}{
+		{http.StatusCreated, true},
+		{http.StatusBadRequest, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusConflict, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			h := newIdempotentHandler(t)
+			calls := 0
+			handler := h.idempotent(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, "call %d", calls)
+			})
+
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("POST", "/order", strings.NewReader(`{"items":[]}`))
+				req.Header.Set("Idempotency-Key", "key")
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+				if rec.Code != tt.status {
+					t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, tt.status)
+				}
+			}
+
+			wantCalls := 2
+			if tt.wantStored {
+				wantCalls = 1
+			}
+			if calls != wantCalls {
+				t.Errorf("handler ran %d times for two requests, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotentBodyLimit(t *testing.T) {
+	h := newIdempotentHandler(t)
+	handler := h.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran for a body over the limit")
+	})
+
+	body := `{"note":"` + strings.Repeat("x", maxIdempotentBody) + `"}`
+	req := httptest.NewRequest("POST", "/order", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "key")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/images"
+)
+
+// maxImageUpload bounds the size of an uploaded image file.
+const maxImageUpload = 10 << 20
+
+// UploadProductImage handles POST /product/{productId}/image. The image is
+// sent as the "image" field of a multipart form or as the raw request body.
+// Its renditions replace the product's image URLs.
+func (h *Handler) UploadProductImage(w http.ResponseWriter, r *http.Request) {
+	product, err := h.productRepo.GetByID(mux.Vars(r)["productId"])
+	if err != nil {
+		http.Error(w, "Error fetching product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if product == nil || product.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUpload)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			if isTooLarge(err) {
+				http.Error(w, "Image is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Form field image is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	image, err := h.imageStore.Save(product.ID, body)
+	if err != nil {
+		switch {
+		case isTooLarge(err):
+			http.Error(w, "Image is too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, images.ErrUnsupportedFormat), errors.Is(err, images.ErrTooLarge):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Error storing image: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	updated, err := h.productRepo.SetImage(product.ID, *image)
+	if err != nil || updated == nil {
+		h.removeUnusedImage(image.Thumbnail)
+		if err != nil {
+			http.Error(w, "Error updating product: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	// Drop the files of the image this one replaces
+	if product.Image.Thumbnail != image.Thumbnail {
+		h.removeUnusedImage(product.Image.Thumbnail)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// removeUnusedImage removes the files of the image with the given thumbnail
+// URL unless a product, such as one an identical upload was stored for, or a
+// past order still shows it.
+func (h *Handler) removeUnusedImage(url string) {
+	inUse, err := h.productRepo.ImageInUse(url)
+	if err == nil && !inUse {
+		inUse, err = h.orderRepo.ImageInUse(url)
+	}
+	if err != nil {
+		log.Printf("Keeping image %s: %v", url, err)
+		return
+	}
+	if inUse {
+		return
+	}
+
+	if err := h.imageStore.Remove(url); err != nil {
+		log.Printf("Failed to remove image %s: %v", url, err)
+	}
+}
+
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// priceModifiers checks a selection of modifier IDs against the product's
+// modifier groups and returns the total price delta of the selection.
+func priceModifiers(product *model.Product, selected []string) (float64, error) {
+	chosen := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		if chosen[id] {
+			return 0, fmt.Errorf("modifier %s selected more than once", id)
+		}
+		chosen[id] = true
+	}
+
+	var delta float64
+	for _, group := range product.ModifierGroups {
+		count := 0
+		for _, modifier := range group.Modifiers {
+			if chosen[modifier.ID] {
+				delete(chosen, modifier.ID)
+				delta += modifier.PriceDelta
+				count++
+			}
+		}
+
+		if count < group.MinSelect {
+			if group.MinSelect == 1 {
+				return 0, fmt.Errorf("a choice of %s is required", group.Name)
+			}
+			return 0, fmt.Errorf("at least %d choices of %s are required", group.MinSelect, group.Name)
+		}
+		if group.MaxSelect == 1 && count > 1 {
+			return 0, fmt.Errorf("only one choice of %s is allowed", group.Name)
+		}
+		if group.MaxSelect > 0 && count > group.MaxSelect {
+			return 0, fmt.Errorf("at most %d choices of %s are allowed", group.MaxSelect, group.Name)
+		}
+	}
+
+	for _, id := range selected {
+		if chosen[id] {
+			return 0, fmt.Errorf("unknown modifier %s", id)
+		}
+	}
+
+	return delta, nil
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// parseOrderFilter reads the GET /order query parameters.
+func (h *Handler) parseOrderFilter(r *http.Request) (repository.OrderFilter, error) {
+	query := r.URL.Query()
+	filter := repository.OrderFilter{
+		CouponCode: query.Get("couponCode"),
+		ProductID:  query.Get("productId"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+
+	var err error
+	if filter.Limit, err = parseLimit(r); err != nil {
+		return filter, err
+	}
+	if filter.CreatedFrom, err = h.parseTimeParam(r, "createdFrom", false); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = h.parseTimeParam(r, "createdTo", true); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp or YYYY-MM-DD date
+// query parameter. Dates are in the store's timezone and stand for the start
+// of the day, or for its end if endOfDay is set.
+func (h *Handler) parseTimeParam(r *http.Request, name string, endOfDay bool) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, h.location)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
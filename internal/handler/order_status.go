@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// UpdateOrderStatus handles PATCH /admin/order/{orderId}/status
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	var update model.OrderStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.updateOrderStatus(w, r, update)
+}
+
+// CancelOrder handles POST /admin/order/{orderId}/cancel. The body is
+// optional and may name the actor and give a note.
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	var update model.OrderStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	update.Status = model.OrderCancelled
+	h.updateOrderStatus(w, r, update)
+}
+
+// updateOrderStatus applies a status change and writes the updated order.
+func (h *Handler) updateOrderStatus(w http.ResponseWriter, r *http.Request, update model.OrderStatusUpdate) {
+	order, err := h.orderRepo.UpdateStatus(mux.Vars(r)["orderId"], update)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUnknownStatus):
+			http.Error(w, "Validation Exception: "+err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, repository.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Error updating order status: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if order == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// GetOrderStatusHistory handles GET /order/{orderId}/status
+func (h *Handler) GetOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.orderRepo.StatusHistory(mux.Vars(r)["orderId"])
+	if err != nil {
+		http.Error(w, "Error getting order status history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if history == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
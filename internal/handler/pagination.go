@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// parseLimit reads the limit query parameter, applying the default and
+// maximum page sizes.
+func parseLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return limit, nil
+}
+
+// parseOrder reads the order query parameter and reports whether the listing
+// is in descending order.
+func parseOrder(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("order must be asc or desc")
+	}
+}
+
+// parseFloatParam reads an optional numeric query parameter.
+func parseFloatParam(r *http.Request, name string) (*float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &f, nil
+}
+
+// setPageHeaders writes listing metadata: the total number of matching rows
+// and, when there is a next page, its cursor and a Link to it.
+func setPageHeaders(w http.ResponseWriter, r *http.Request, total int, nextCursor string) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if nextCursor == "" {
+		return
+	}
+
+	w.Header().Set("X-Next-Cursor", nextCursor)
+
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/catalog"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// UpdateProduct handles PUT /product/{productId}
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	var product model.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	product.ID = mux.Vars(r)["productId"]
+	h.saveProduct(w, r, &product)
+}
+
+// PatchProduct handles PATCH /product/{productId}
+func (h *Handler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	var patch model.ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productRepo.GetByID(mux.Vars(r)["productId"])
+	if err != nil {
+		http.Error(w, "Error fetching product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if product == nil || product.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if patch.Name != nil {
+		product.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		product.Description = *patch.Description
+	}
+	if patch.Price != nil {
+		product.Price = *patch.Price
+	}
+	if patch.Category != nil {
+		product.Category = *patch.Category
+		product.CategoryID = ""
+	}
+	if patch.CategoryID != nil {
+		product.CategoryID = *patch.CategoryID
+	}
+	if patch.Image != nil {
+		product.Image = *patch.Image
+	}
+	if patch.ModifierGroups != nil {
+		product.ModifierGroups = *patch.ModifierGroups
+	}
+	if patch.Availability != nil {
+		product.Availability = *patch.Availability
+	}
+
+	h.saveProduct(w, r, product)
+}
+
+// saveProduct validates and stores an updated product.
+func (h *Handler) saveProduct(w http.ResponseWriter, r *http.Request, product *model.Product) {
+	if err := catalog.Validate(product); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.productRepo.Update(product)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrModifierExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if updated == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteProduct handles DELETE /product/{productId}. Products are soft
+// deleted so orders that reference them can still resolve them.
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	found, err := h.productRepo.Delete(mux.Vars(r)["productId"])
+	if err != nil {
+		http.Error(w, "Error deleting product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchProducts handles GET /product/search?q=. Like GET /product it leaves
+// out products that cannot be ordered now unless includeUnavailable is set.
+func (h *Handler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availableAt, err := h.availableAt(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	products, err := h.productRepo.Search(q, limit, availableAt)
+	if err != nil {
+		http.Error(w, "Error searching products: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+// availableAt returns the time listings should check availability at, or nil
+// if the includeUnavailable query parameter asks for every product.
+func (h *Handler) availableAt(r *http.Request) (*time.Time, error) {
+	if v := r.URL.Query().Get("includeUnavailable"); v != "" {
+		all, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("includeUnavailable must be true or false")
+		}
+		if all {
+			return nil, nil
+		}
+	}
+
+	now := h.now()
+	return &now, nil
+}
+
+// parseProductFilter reads the GET /product query parameters.
+func parseProductFilter(r *http.Request) (repository.ProductFilter, error) {
+	query := r.URL.Query()
+	filter := repository.ProductFilter{
+		Category:   query.Get("category"),
+		CategoryID: query.Get("categoryId"),
+		Sort:       query.Get("sort"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	switch filter.Sort {
+	case "", repository.ProductSortName, repository.ProductSortPrice, repository.ProductSortCreatedAt:
+	default:
+		return filter, fmt.Errorf("sort must be one of %s, %s or %s",
+			repository.ProductSortName, repository.ProductSortPrice, repository.ProductSortCreatedAt)
+	}
+
+	var err error
+	if filter.Desc, err = parseOrder(r); err != nil {
+		return filter, err
+	}
+	// Without limit or cursor the whole catalog is listed, as it was before
+	// listings were paged
+	if query.Has("limit") || filter.Cursor != "" {
+		if filter.Limit, err = parseLimit(r); err != nil {
+			return filter, err
+		}
+	}
+	if filter.MinPrice, err = parseFloatParam(r, "minPrice"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = parseFloatParam(r, "maxPrice"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
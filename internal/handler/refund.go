@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// RefundOrder handles POST /admin/order/{orderId}/refund. Without a body, or
+// without items, the whole amount not refunded yet is refunded.
+func (h *Handler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	var request model.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderRepo.Refund(mux.Vars(r)["orderId"], request)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidRefund):
+			http.Error(w, "Validation Exception: "+err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, repository.ErrNothingToRefund), errors.Is(err, repository.ErrOrderNotRefundable),
+			errors.Is(err, repository.ErrUnpricedOrder):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Error refunding order: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if order == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/repository"
+)
+
+// SetStock handles PUT /admin/product/{productId}/stock
+func (h *Handler) SetStock(w http.ResponseWriter, r *http.Request) {
+	var level model.StockLevel
+	if err := json.NewDecoder(r.Body).Decode(&level); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if level.Stock != nil && *level.Stock < 0 {
+		http.Error(w, "Stock cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productRepo.SetStock(mux.Vars(r)["productId"], level.Stock)
+	h.writeStock(w, r, product, err)
+}
+
+// AdjustStock handles PATCH /admin/product/{productId}/stock
+func (h *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	var adjustment model.StockAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if adjustment.Delta == 0 {
+		http.Error(w, "Delta must not be 0", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productRepo.AdjustStock(mux.Vars(r)["productId"], adjustment.Delta)
+	h.writeStock(w, r, product, err)
+}
+
+// RestockProduct handles POST /admin/product/{productId}/restock
+func (h *Handler) RestockProduct(w http.ResponseWriter, r *http.Request) {
+	var restock model.Restock
+	if err := json.NewDecoder(r.Body).Decode(&restock); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if restock.Quantity <= 0 {
+		http.Error(w, "Quantity must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productRepo.AdjustStock(mux.Vars(r)["productId"], restock.Quantity)
+	h.writeStock(w, r, product, err)
+}
+
+// writeStock writes the outcome of a stock change.
+func (h *Handler) writeStock(w http.ResponseWriter, r *http.Request, product *model.Product, err error) {
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrStockNotTracked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating stock: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if product == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
@@ -0,0 +1,69 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Fill scales src to cover a width x height box, cropping whatever overflows
+// equally on both sides. Each destination pixel averages the source pixels it
+// covers, which keeps downscaled images smooth. Transparent pixels are drawn
+// over white, as the renditions are JPEGs.
+func Fill(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	crop := b
+	// Compare aspect ratios without dividing: src wider than the box when
+	// b.Dx()/b.Dy() > width/height.
+	if b.Dx()*height > b.Dy()*width {
+		w := max(b.Dy()*width/height, 1)
+		crop.Min.X = b.Min.X + (b.Dx()-w)/2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := max(b.Dx()*height/width, 1)
+		crop.Min.Y = b.Min.Y + (b.Dy()-h)/2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	rgba := toRGBA(src, crop)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max((y+1)*sh/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max((x+1)*sw/width, x0+1)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// toRGBA draws the r part of src over white into an RGBA image with its
+// origin at 0,0.
+func toRGBA(src image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Over)
+	return dst
+}
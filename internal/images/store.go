@@ -0,0 +1,234 @@
+// Package images stores uploaded product images and the renditions the API
+// serves for them.
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// URLPrefix is the path images are served under.
+const URLPrefix = "/images/"
+
+// MaxPixels bounds the size of an uploaded image once decoded. Decoding one
+// this size takes up to 64 MB.
+const MaxPixels = 16_000_000
+
+// maxConcurrentSaves bounds how many uploads are decoded and resized at
+// once, and with it the memory they take.
+const maxConcurrentSaves = 2
+
+var (
+	ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooLarge          = fmt.Errorf("image exceeds %d pixels", MaxPixels)
+)
+
+// Rendition is a generated size of a product image.
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Renditions are the sizes generated for every upload, one per field of
+// model.Image.
+var Renditions = []Rendition{
+	{Name: "thumbnail", Width: 100, Height: 100},
+	{Name: "mobile", Width: 654, Height: 424},
+	{Name: "tablet", Width: 427, Height: 424},
+	{Name: "desktop", Width: 502, Height: 480},
+}
+
+// Store keeps images as flat files in a directory. An upload's files share a
+// name prefix derived from the product and the image content, so a new upload
+// never overwrites files a client may have cached.
+type Store struct {
+	dir   string
+	saves chan struct{}
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, saves: make(chan struct{}, maxConcurrentSaves)}
+}
+
+// Save decodes an uploaded image, stores it and generates its renditions,
+// returning their URLs. Uploads wait for their turn to be processed. Files
+// are only put in place once all of them are written, and if storing fails
+// the ones this call created are removed; files of an identical earlier
+// upload are left alone.
+func (s *Store) Save(productID string, r io.Reader) (*model.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.saves <- struct{}{}
+	defer func() { <-s.saves }()
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating image directory: %w", err)
+	}
+
+	sum := sha256.Sum256(append([]byte(productID+"\x00"), data...))
+	prefix := hex.EncodeToString(sum[:8])
+
+	files := []file{{name: prefix + "-original." + format, data: data}}
+	urls := make(map[string]string, len(Renditions))
+	for _, rendition := range Renditions {
+		var buf bytes.Buffer
+		img := Fill(src, rendition.Width, rendition.Height)
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("error encoding %s image: %w", rendition.Name, err)
+		}
+
+		name := prefix + "-" + rendition.Name + ".jpg"
+		files = append(files, file{name: name, data: buf.Bytes()})
+		urls[rendition.Name] = URLPrefix + name
+	}
+
+	if err := s.write(files); err != nil {
+		return nil, err
+	}
+
+	return &model.Image{
+		Thumbnail: urls["thumbnail"],
+		Mobile:    urls["mobile"],
+		Tablet:    urls["tablet"],
+		Desktop:   urls["desktop"],
+	}, nil
+}
+
+// Remove deletes the files of the upload that url, one of its rendition
+// URLs, belongs to. URLs not served by the store are ignored.
+func (s *Store) Remove(url string) error {
+	name, ok := strings.CutPrefix(url, URLPrefix)
+	if !ok || strings.Contains(name, "/") {
+		return nil
+	}
+	prefix, _, ok := strings.Cut(name, "-")
+	if !ok {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.dir, prefix+"-*"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves stored images under URLPrefix.
+func (s *Store) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.StripPrefix(strings.TrimSuffix(URLPrefix, "/"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Serve files only, not directory listings
+		if strings.HasSuffix(r.URL.Path, "/") || path.Dir(r.URL.Path) != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// file is a file to store.
+type file struct {
+	name string
+	data []byte
+}
+
+// write stores files atomically so readers never see a partial image: each is
+// written to a temporary file, and once all are written they are renamed into
+// place. If that fails, the files it created are removed again.
+func (s *Store) write(files []file) (err error) {
+	tmps := make([]string, len(files))
+	defer func() {
+		for _, tmp := range tmps {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}
+	}()
+	for i, f := range files {
+		if tmps[i], err = writeTemp(s.dir, f.data); err != nil {
+			return fmt.Errorf("error storing image: %w", err)
+		}
+	}
+
+	var created []string
+	defer func() {
+		if err != nil {
+			for _, target := range created {
+				os.Remove(target)
+			}
+		}
+	}()
+	for i, f := range files {
+		target := filepath.Join(s.dir, f.name)
+		_, statErr := os.Stat(target)
+		if err := os.Rename(tmps[i], target); err != nil {
+			return fmt.Errorf("error storing image: %w", err)
+		}
+		tmps[i] = ""
+		if errors.Is(statErr, os.ErrNotExist) {
+			created = append(created, target)
+		}
+	}
+	return nil
+}
+
+// writeTemp writes data to a new temporary file in dir and returns its path.
+func writeTemp(dir string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
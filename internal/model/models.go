@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type HeartbeatResponse struct {
 	Status string `json:"status"`
 	Code   int    `json:"code"`
@@ -13,29 +15,227 @@ type Image struct {
 }
 
 type Product struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Category string  `json:"category"`
-	Image    Image   `json:"image"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Price       float64    `json:"price"`
+	Category    string     `json:"category"`
+	CategoryID  string     `json:"categoryId,omitempty"`
+	Image       Image      `json:"image"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+
+	// Stock is the number of units left, or nil if stock is not tracked. It
+	// can be set on creation; afterwards it only changes through orders and
+	// the stock endpoints.
+	Stock *int `json:"stock,omitempty"`
+
+	// ModifierGroups are the options offered with the product. On update a
+	// nil list leaves the existing groups unchanged and an empty list
+	// removes them.
+	ModifierGroups []ModifierGroup `json:"modifierGroups,omitempty"`
+
+	// Availability limits when the product can be ordered. Without windows
+	// of its own the product follows its category's. On update a nil list
+	// leaves the windows unchanged and an empty list removes them.
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
+}
+
+// AvailabilityWindow is a weekly time range, in the store's timezone, in
+// which an item can be ordered. Start and End are "HH:MM"; a window ending
+// before it starts runs past midnight. No Days means every day.
+type AvailabilityWindow struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// ModifierGroup is a set of choices for a product, such as its size or
+// add-ons. A customer picks between MinSelect and MaxSelect modifiers from
+// it; MaxSelect 0 means no upper limit.
+type ModifierGroup struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Required  bool       `json:"required"`
+	MinSelect int        `json:"minSelect"`
+	MaxSelect int        `json:"maxSelect"`
+	Modifiers []Modifier `json:"modifiers"`
+}
+
+type Modifier struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"priceDelta"`
+}
+
+// ProductPatch holds the fields of a partial product update; nil fields are
+// left unchanged.
+type ProductPatch struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Category    *string  `json:"category"`
+	CategoryID  *string  `json:"categoryId"`
+	Image       *Image   `json:"image"`
+
+	ModifierGroups *[]ModifierGroup      `json:"modifierGroups"`
+	Availability   *[]AvailabilityWindow `json:"availability"`
+}
+
+// StockLevel sets a product's stock; a null stock stops tracking it.
+type StockLevel struct {
+	Stock *int `json:"stock"`
+}
+
+// StockAdjustment changes a product's stock by Delta units.
+type StockAdjustment struct {
+	Delta int `json:"delta"`
+}
+
+// Restock adds Quantity units to a product's stock.
+type Restock struct {
+	Quantity int `json:"quantity"`
+}
+
+type Category struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Image        string `json:"image,omitempty"`
+	DisplayOrder int    `json:"displayOrder"`
+
+	// Availability applies to the category's products that have no windows
+	// of their own. On update a nil list leaves the windows unchanged.
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
 }
 
 type OrderItem struct {
 	ProductID string `json:"productId"`
 	Quantity  int    `json:"quantity"`
+	// Modifiers are the IDs of the modifiers chosen for the item.
+	Modifiers []string `json:"modifiers,omitempty"`
+	// UnitPrice is the price charged per unit, including modifiers. It is
+	// set by the server.
+	UnitPrice float64 `json:"unitPrice,omitempty"`
 }
 
 type OrderRequest struct {
-	CouponCode string      `json:"couponCode,omitempty"`
-	Items      []OrderItem `json:"items"`
+	CouponCode  string      `json:"couponCode,omitempty"`
+	CouponCodes []string    `json:"couponCodes,omitempty"`
+	CustomerID  string      `json:"customerId,omitempty"`
+	Items       []OrderItem `json:"items"`
+}
+
+type AppliedDiscount struct {
+	Code   string  `json:"code"`
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount"`
 }
 
+// Order is a placed order. Products[i] is a snapshot of the product of
+// Items[i] as it was when the order was placed.
 type Order struct {
-	ID        string      `json:"id"`
-	Total     float64     `json:"total"`
-	Discounts float64     `json:"discounts"`
-	Items     []OrderItem `json:"items"`
-	Products  []Product   `json:"products"`
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Total            float64           `json:"total"`
+	Discounts        float64           `json:"discounts"`
+	CustomerID       string            `json:"customerId,omitempty"`
+	AppliedDiscounts []AppliedDiscount `json:"appliedDiscounts,omitempty"`
+	Items            []OrderItem       `json:"items"`
+	Products         []Product         `json:"products"`
+	// Refunded is the sum of the amounts of Refunds.
+	Refunded  float64   `json:"refunded,omitempty"`
+	Refunds   []Refund  `json:"refunds,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Order statuses. An order starts pending and moves through confirmed,
+// preparing and ready to completed. A pending order can be rejected, and one
+// that is not ready yet can be cancelled.
+const (
+	OrderPending   = "pending"
+	OrderConfirmed = "confirmed"
+	OrderPreparing = "preparing"
+	OrderReady     = "ready"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+	OrderRejected  = "rejected"
+)
+
+// OrderStatusUpdate moves an order to Status. Actor names who made the
+// change and Note may say why.
+type OrderStatusUpdate struct {
+	Status string `json:"status"`
+	Actor  string `json:"actor,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// StatusChange is an entry of an order's status history. From is empty for
+// the entry recorded when the order was placed.
+type StatusChange struct {
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// RefundRequest asks for part of an order to be refunded. Without Items the
+// whole amount not refunded yet is.
+type RefundRequest struct {
+	Items  []RefundLine `json:"items,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+	Actor  string       `json:"actor,omitempty"`
+}
+
+// RefundLine is the refund of Quantity units of the order line at index Line
+// of the order's items. A zero Quantity refunds all units of the line not
+// refunded yet. Amount is set by the server.
+type RefundLine struct {
+	Line     int     `json:"line"`
+	Quantity int     `json:"quantity,omitempty"`
+	Amount   float64 `json:"amount,omitempty"`
+}
+
+// Refund is money returned on an order. Line amounts share the order's
+// discounts in proportion to their price.
+type Refund struct {
+	ID        string       `json:"id"`
+	Amount    float64      `json:"amount"`
+	Reason    string       `json:"reason,omitempty"`
+	Actor     string       `json:"actor,omitempty"`
+	Items     []RefundLine `json:"items,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type CouponValidationRequest struct {
+	Code       string      `json:"code"`
+	CustomerID string      `json:"customerId,omitempty"`
+	Items      []OrderItem `json:"items,omitempty"`
+}
+
+type CouponValidation struct {
+	Code     string  `json:"code"`
+	Valid    bool    `json:"valid"`
+	Reason   string  `json:"reason,omitempty"`
+	Discount float64 `json:"discount"`
+	Subtotal float64 `json:"subtotal,omitempty"`
+	Total    float64 `json:"total,omitempty"`
+}
+
+type PromoCode struct {
+	Code           string     `json:"code"`
+	Type           string     `json:"type"`
+	Value          float64    `json:"value,omitempty"`
+	MaxDiscount    float64    `json:"maxDiscount,omitempty"`
+	ProductID      string     `json:"productId,omitempty"`
+	Quantity       int        `json:"quantity,omitempty"`
+	MaxRedemptions int        `json:"maxRedemptions,omitempty"`
+	MaxPerCustomer int        `json:"maxPerCustomer,omitempty"`
+	StartsAt       *time.Time `json:"startsAt,omitempty"`
+	EndsAt         *time.Time `json:"endsAt,omitempty"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
 }
 
 type ApiResponse struct {
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+	"github.com/ravip18596/order-food-online/internal/schedule"
+)
+
+// Owners of availability windows; the values are availability_windows
+// columns.
+const (
+	availabilityProduct  = "product_id"
+	availabilityCategory = "category_id"
+)
+
+type AvailabilityDB struct {
+	ID          int64   `db:"id"`
+	ProductID   *string `db:"product_id"`
+	CategoryID  *string `db:"category_id"`
+	WindowIndex int     `db:"window_index"`
+	Day         int     `db:"day"`
+	StartMinute int     `db:"start_minute"`
+	EndMinute   int     `db:"end_minute"`
+}
+
+// saveAvailability replaces the availability windows of a product or
+// category.
+func saveAvailability(tx *sqlx.Tx, owner, id string, windows []model.AvailabilityWindow) error {
+	slots, err := schedule.Parse(windows)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM availability_windows WHERE `+owner+` = ?`, id); err != nil {
+		return fmt.Errorf("error removing availability: %w", err)
+	}
+
+	query := `INSERT INTO availability_windows (` + owner + `, window_index, day, start_minute, end_minute) VALUES (?, ?, ?, ?, ?)`
+	for _, slot := range slots {
+		if _, err := tx.Exec(query, id, slot.Window, int(slot.Day), slot.Start, slot.End); err != nil {
+			return fmt.Errorf("error saving availability: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadAvailability returns the availability windows of the given products or
+// categories, keyed by ID.
+func loadAvailability(db *sqlx.DB, owner string, ids []string) (map[string][]model.AvailabilityWindow, error) {
+	windows := make(map[string][]model.AvailabilityWindow)
+	if len(ids) == 0 {
+		return windows, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM availability_windows
+		WHERE `+owner+` IN (?)
+		ORDER BY window_index, id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error building availability query: %w", err)
+	}
+
+	var rows []AvailabilityDB
+	if err := db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("error fetching availability: %w", err)
+	}
+
+	slots := make(map[string][]schedule.Slot)
+	var order []string
+	for _, row := range rows {
+		id := stringValue(row.ProductID)
+		if owner == availabilityCategory {
+			id = stringValue(row.CategoryID)
+		}
+		if _, ok := slots[id]; !ok {
+			order = append(order, id)
+		}
+		slots[id] = append(slots[id], schedule.Slot{
+			Window: row.WindowIndex,
+			Day:    time.Weekday(row.Day),
+			Start:  row.StartMinute,
+			End:    row.EndMinute,
+		})
+	}
+
+	for _, id := range order {
+		windows[id] = schedule.Windows(slots[id])
+	}
+	return windows, nil
+}
+
+// availableCondition returns an SQL condition on products that holds when a
+// product can be ordered at t, in t's location. A product with windows of its
+// own must be inside one of them; otherwise it must be inside one of its
+// category's, if the category has any.
+func availableCondition(t time.Time) (string, []interface{}) {
+	day := int(t.Weekday())
+	previous := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+
+	// A slot is open on its own day from its start, and on the next day
+	// until its end if it runs past midnight.
+	open := func(owner, id string) (string, []interface{}) {
+		cond := `EXISTS (
+			SELECT 1 FROM availability_windows w
+			WHERE w.` + owner + ` = ` + id + ` AND (
+				(w.day = ? AND w.start_minute <= ? AND (? < w.end_minute OR w.end_minute <= w.start_minute))
+				OR (w.day = ? AND w.end_minute <= w.start_minute AND ? < w.end_minute)
+			)
+		)`
+		return cond, []interface{}{day, minute, minute, previous, minute}
+	}
+
+	productOpen, productArgs := open(availabilityProduct, "products.id")
+	categoryOpen, categoryArgs := open(availabilityCategory, "products.category_id")
+	cond := `(CASE
+		WHEN EXISTS (SELECT 1 FROM availability_windows WHERE product_id = products.id) THEN ` + productOpen + `
+		WHEN EXISTS (SELECT 1 FROM availability_windows WHERE category_id = products.category_id) THEN ` + categoryOpen + `
+		ELSE 1
+	END)`
+	return cond, append(productArgs, categoryArgs...)
+}
+
+// IsAvailable reports whether the product can be ordered at t, following the
+// product's or its category's availability windows.
+func (r *ProductRepository) IsAvailable(id string, t time.Time) (bool, error) {
+	cond, args := availableCondition(t)
+
+	var available bool
+	err := r.db.Get(&available, `SELECT `+cond+` FROM products WHERE id = ?`, append(args, id)...)
+	if err != nil {
+		return false, fmt.Errorf("error checking availability: %w", err)
+	}
+	return available, nil
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("a category with this name already exists")
+	ErrCategoryInUse    = errors.New("category still has products")
+)
+
+type CategoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewCategoryRepository(db *sqlx.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+type CategoryDB struct {
+	ID           string    `db:"id"`
+	Name         string    `db:"name"`
+	Description  string    `db:"description"`
+	Image        string    `db:"image"`
+	DisplayOrder int       `db:"display_order"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+func (c CategoryDB) toModel() *model.Category {
+	return &model.Category{
+		ID:           c.ID,
+		Name:         c.Name,
+		Description:  c.Description,
+		Image:        c.Image,
+		DisplayOrder: c.DisplayOrder,
+	}
+}
+
+func (r *CategoryRepository) Create(category *model.Category) (*model.Category, error) {
+	if category.ID == "" {
+		category.ID = uuid.New().String()
+	}
+	category.Name = strings.TrimSpace(category.Name)
+
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		query := `
+			INSERT INTO categories (id, name, description, image, display_order)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		_, err := tx.Exec(query, category.ID, category.Name, category.Description, category.Image, category.DisplayOrder)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrCategoryExists
+			}
+			return fmt.Errorf("error creating category: %w", err)
+		}
+
+		return saveAvailability(tx, availabilityCategory, category.ID, category.Availability)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(category.ID)
+}
+
+func (r *CategoryRepository) GetByID(id string) (*model.Category, error) {
+	var categoryDB CategoryDB
+	err := r.db.Get(&categoryDB, `SELECT * FROM categories WHERE id = ?`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching category: %w", err)
+	}
+
+	category := categoryDB.toModel()
+	if err := r.loadAvailability([]*model.Category{category}); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// List returns all categories in display order.
+func (r *CategoryRepository) List() ([]*model.Category, error) {
+	var categoriesDB []CategoryDB
+	err := r.db.Select(&categoriesDB, `SELECT * FROM categories ORDER BY display_order, name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching categories: %w", err)
+	}
+
+	categories := make([]*model.Category, len(categoriesDB))
+	for i, categoryDB := range categoriesDB {
+		categories[i] = categoryDB.toModel()
+	}
+
+	if err := r.loadAvailability(categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *CategoryRepository) loadAvailability(categories []*model.Category) error {
+	ids := make([]string, len(categories))
+	for i, category := range categories {
+		ids[i] = category.ID
+	}
+
+	availability, err := loadAvailability(r.db, availabilityCategory, ids)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		category.Availability = availability[category.ID]
+	}
+	return nil
+}
+
+// Update replaces a category's fields, and its availability windows unless
+// they are nil. A rename is propagated to the
+// category name stored on its products. It returns nil if there is no such
+// category.
+func (r *CategoryRepository) Update(category *model.Category) (*model.Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+
+	found := false
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		query := `
+			UPDATE categories SET name = ?, description = ?, image = ?, display_order = ?
+			WHERE id = ?
+		`
+		res, err := tx.Exec(query, category.Name, category.Description, category.Image, category.DisplayOrder, category.ID)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrCategoryExists
+			}
+			return fmt.Errorf("error updating category: %w", err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		found = true
+
+		_, err = tx.Exec(`UPDATE products SET category = ? WHERE category_id = ? AND category != ?`,
+			category.Name, category.ID, category.Name)
+		if err != nil {
+			return fmt.Errorf("error renaming product categories: %w", err)
+		}
+
+		if category.Availability != nil {
+			return saveAvailability(tx, availabilityCategory, category.ID, category.Availability)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return r.GetByID(category.ID)
+}
+
+// Delete removes a category that no current product belongs to. Deleted
+// products keep the category name but lose the reference. It returns false
+// if there is no such category.
+func (r *CategoryRepository) Delete(id string) (bool, error) {
+	found := false
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		var inUse bool
+		err := tx.Get(&inUse, `SELECT COUNT(*) > 0 FROM products WHERE category_id = ? AND deleted_at IS NULL`, id)
+		if err != nil {
+			return fmt.Errorf("error checking category products: %w", err)
+		}
+		if inUse {
+			return ErrCategoryInUse
+		}
+
+		if _, err = tx.Exec(`UPDATE products SET category_id = NULL WHERE category_id = ?`, id); err != nil {
+			return fmt.Errorf("error unlinking deleted products: %w", err)
+		}
+
+		res, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("error deleting category: %w", err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error deleting category: %w", err)
+		}
+		found = n > 0
+		return nil
+	})
+	return found, err
+}
+
+// resolveCategory points product at its category. A CategoryID must refer to
+// an existing category; otherwise the category is looked up by name, ignoring
+// case, and created if it does not exist yet. The product's category name is
+// normalised to the category's.
+func resolveCategory(db sqlx.Ext, product *model.Product) error {
+	var categoryDB CategoryDB
+	if product.CategoryID != "" {
+		err := sqlx.Get(db, &categoryDB, `SELECT * FROM categories WHERE id = ?`, product.CategoryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCategoryNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching category: %w", err)
+		}
+	} else {
+		name := strings.TrimSpace(product.Category)
+		_, err := db.Exec(`INSERT INTO categories (id, name) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`,
+			uuid.New().String(), name)
+		if err != nil {
+			return fmt.Errorf("error creating category: %w", err)
+		}
+		err = sqlx.Get(db, &categoryDB, `SELECT * FROM categories WHERE name = ?`, name)
+		if err != nil {
+			return fmt.Errorf("error fetching category: %w", err)
+		}
+	}
+
+	product.CategoryID = categoryDB.ID
+	product.Category = categoryDB.Name
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
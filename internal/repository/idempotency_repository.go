@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// IdempotencyLockTimeout is how long a key stays claimed by a request that
+// has not finished. Past it the request is assumed to have died with the
+// server and another may claim the key. Keys must be kept for longer.
+const IdempotencyLockTimeout = time.Minute
+
+// IdempotencyRepository remembers the responses to requests sent with an
+// Idempotency-Key so that retries can be answered without repeating them.
+type IdempotencyRepository struct {
+	db  *sqlx.DB
+	ttl time.Duration
+}
+
+// NewIdempotencyRepository returns a repository keeping keys for ttl after
+// they are first used.
+func NewIdempotencyRepository(db *sqlx.DB, ttl time.Duration) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db, ttl: ttl}
+}
+
+type IdempotencyKeyDB struct {
+	Key         string    `db:"key"`
+	Fingerprint string    `db:"fingerprint"`
+	StatusCode  *int      `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Response    []byte    `db:"response"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// IdempotentResponse is a stored response.
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Begin claims key for a request with the given fingerprint. It returns nil
+// if the request should go ahead, in which case Complete or Release must
+// follow, and the stored response if the same request was already answered.
+// It fails with ErrIdempotencyKeyReused if the key was used for a different
+// request, and with ErrIdempotencyKeyInProgress if the first request with the
+// key has not finished yet. Expired keys are forgotten.
+func (r *IdempotencyRepository) Begin(key, fingerprint string) (*IdempotentResponse, error) {
+	var response *IdempotentResponse
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`
+			DELETE FROM idempotency_keys
+			WHERE expires_at <= datetime('now')
+			OR (status_code IS NULL AND created_at <= datetime('now', ?))
+		`, sqliteModifier(-IdempotencyLockTimeout))
+		if err != nil {
+			return fmt.Errorf("error removing expired idempotency keys: %w", err)
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+			VALUES (?, ?, datetime('now', ?))
+			ON CONFLICT (key) DO NOTHING
+		`, key, fingerprint, sqliteModifier(r.ttl))
+		if err != nil {
+			return fmt.Errorf("error claiming idempotency key: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("error claiming idempotency key: %w", err)
+		} else if n == 1 {
+			return nil
+		}
+
+		var keyDB IdempotencyKeyDB
+		if err := tx.Get(&keyDB, `SELECT * FROM idempotency_keys WHERE key = ?`, key); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrIdempotencyKeyInProgress
+			}
+			return fmt.Errorf("error fetching idempotency key: %w", err)
+		}
+		if keyDB.Fingerprint != fingerprint {
+			return ErrIdempotencyKeyReused
+		}
+		if keyDB.StatusCode == nil {
+			return ErrIdempotencyKeyInProgress
+		}
+
+		response = &IdempotentResponse{
+			StatusCode:  *keyDB.StatusCode,
+			ContentType: keyDB.ContentType,
+			Body:        keyDB.Response,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Complete stores the response to the request that claimed key.
+func (r *IdempotencyRepository) Complete(key string, response IdempotentResponse) error {
+	query := `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, response = ?
+		WHERE key = ? AND status_code IS NULL
+	`
+	_, err := r.db.Exec(query, response.StatusCode, response.ContentType, response.Body, key)
+	if err != nil {
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release gives up the claim on key without storing a response, so the
+// request can be retried with it.
+func (r *IdempotencyRepository) Release(key string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE key = ? AND status_code IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// sqliteModifier formats d as a datetime() modifier.
+func sqliteModifier(d time.Duration) string {
+	return fmt.Sprintf("%+d seconds", int64(d/time.Second))
+}
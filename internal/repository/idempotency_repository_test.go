@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplay(t *testing.T) {
+	repo := NewIdempotencyRepository(newTestDB(t), time.Hour)
+
+	stored, err := repo.Begin("key", "order")
+	if stored != nil || err != nil {
+		t.Fatalf("first Begin = %v, %v, want nil, nil", stored, err)
+	}
+	if _, err := repo.Begin("key", "order"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Errorf("Begin while in progress error = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	response := IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+	if err := repo.Complete("key", response); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = repo.Begin("key", "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || !reflect.DeepEqual(*stored, response) {
+		t.Errorf("Begin after Complete = %v, want the stored %v", stored, response)
+	}
+
+	if _, err := repo.Begin("key", "other order"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Begin with another fingerprint error = %v, want ErrIdempotencyKeyReused", err)
+	}
+}
+
+func TestIdempotencyRelease(t *testing.T) {
+	repo := NewIdempotencyRepository(newTestDB(t), time.Hour)
+
+	if _, err := repo.Begin("key", "order"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Release("key"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A released key keeps no response, so a retry runs again, even with
+	// another body.
+	stored, err := repo.Begin("key", "other order")
+	if stored != nil || err != nil {
+		t.Errorf("Begin after Release = %v, %v, want nil, nil", stored, err)
+	}
+}
+
+func TestIdempotencyLockTimeout(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewIdempotencyRepository(db, time.Hour)
+
+	if _, err := repo.Begin("key", "order"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A claim younger than the timeout still holds.
+	_, err := db.Exec(`UPDATE idempotency_keys SET created_at = datetime('now', ?) WHERE key = 'key'`,
+		sqliteModifier(-IdempotencyLockTimeout+5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Begin("key", "order"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("Begin before the lock timeout error = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	// Past it the request is taken to have died and the key can be claimed.
+	_, err = db.Exec(`UPDATE idempotency_keys SET created_at = datetime('now', ?) WHERE key = 'key'`,
+		sqliteModifier(-IdempotencyLockTimeout-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.Begin("key", "order")
+	if stored != nil || err != nil {
+		t.Fatalf("Begin after the lock timeout = %v, %v, want nil, nil", stored, err)
+	}
+	if err := repo.Complete("key", IdempotentResponse{StatusCode: 201}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := repo.Begin("key", "order"); err != nil || stored == nil || stored.StatusCode != 201 {
+		t.Errorf("Begin after the new claim completed = %v, %v, want the stored 201", stored, err)
+	}
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+var (
+	ErrInvalidRefund      = errors.New("invalid refund")
+	ErrNothingToRefund    = errors.New("order has nothing left to refund")
+	ErrOrderNotRefundable = errors.New("order cannot be refunded")
+	ErrUnpricedOrder      = errors.New("order lines have no prices")
+)
+
+type RefundDB struct {
+	ID        string    `db:"id"`
+	OrderID   string    `db:"order_id"`
+	Amount    float64   `db:"amount"`
+	Reason    string    `db:"reason"`
+	Actor     string    `db:"actor"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type RefundItemDB struct {
+	ID          string  `db:"id"`
+	RefundID    string  `db:"refund_id"`
+	OrderItemID string  `db:"order_item_id"`
+	Quantity    int     `db:"quantity"`
+	Amount      float64 `db:"amount"`
+}
+
+// refundableLine is an order line with the number of its units refunded so
+// far.
+type refundableLine struct {
+	ID           string  `db:"id"`
+	Quantity     int     `db:"quantity"`
+	PricePerUnit float64 `db:"price_per_unit"`
+	Refunded     int     `db:"refunded"`
+}
+
+// Refund refunds part or all of an order. Once nothing is left to refund the
+// order's coupon redemptions are released. It returns nil if there is no
+// such order.
+func (r *OrderRepository) Refund(id string, request model.RefundRequest) (*model.Order, error) {
+	found := true
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		var status string
+		err := tx.Get(&status, `SELECT status FROM orders WHERE id = ?`, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				found = false
+				return nil
+			}
+			return fmt.Errorf("error fetching order: %w", err)
+		}
+		if status == model.OrderCancelled || status == model.OrderRejected {
+			return fmt.Errorf("%w: order is %s", ErrOrderNotRefundable, status)
+		}
+
+		settled, err := refund(tx, id, request)
+		if err != nil {
+			return err
+		}
+		if settled {
+			return releaseCoupons(tx, id)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// releaseOrder undoes the effects of placing an order that will not be
+// fulfilled: its items go back into stock, its coupon redemptions are
+// released and whatever was not refunded yet is.
+func releaseOrder(tx *sqlx.Tx, id string, update model.OrderStatusUpdate) error {
+	_, err := tx.Exec(`
+		UPDATE products
+		SET stock = stock + (
+			SELECT SUM(quantity) FROM order_items
+			WHERE order_id = ? AND product_id = products.id
+		)
+		WHERE stock IS NOT NULL
+		AND id IN (SELECT product_id FROM order_items WHERE order_id = ?)
+	`, id, id)
+	if err != nil {
+		return fmt.Errorf("error restoring stock: %w", err)
+	}
+
+	if err := releaseCoupons(tx, id); err != nil {
+		return err
+	}
+
+	request := model.RefundRequest{Reason: update.Note, Actor: update.Actor}
+	if _, err := refund(tx, id, request); err != nil && !errors.Is(err, ErrNothingToRefund) {
+		return err
+	}
+	return nil
+}
+
+// releaseCoupons stops the order's coupon redemptions counting against the
+// coupons' limits. They are kept so the order still shows its discounts.
+func releaseCoupons(tx *sqlx.Tx, orderID string) error {
+	_, err := tx.Exec(`
+		UPDATE coupon_redemptions SET released_at = CURRENT_TIMESTAMP
+		WHERE order_id = ? AND released_at IS NULL
+	`, orderID)
+	if err != nil {
+		return fmt.Errorf("error releasing coupon redemptions: %w", err)
+	}
+	return nil
+}
+
+// refund records a refund of the order inside tx and reports whether the
+// order has been refunded in full.
+func refund(tx *sqlx.Tx, orderID string, request model.RefundRequest) (bool, error) {
+	var total, refunded float64
+	if err := tx.Get(&total, `SELECT total FROM orders WHERE id = ?`, orderID); err != nil {
+		return false, fmt.Errorf("error fetching order: %w", err)
+	}
+	err := tx.Get(&refunded, `SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE order_id = ?`, orderID)
+	if err != nil {
+		return false, fmt.Errorf("error fetching refunds: %w", err)
+	}
+
+	var lines []refundableLine
+	err = tx.Select(&lines, `
+		SELECT id, quantity, price_per_unit,
+			COALESCE((SELECT SUM(quantity) FROM refund_items WHERE order_item_id = order_items.id), 0) AS refunded
+		FROM order_items
+		WHERE order_id = ?
+		ORDER BY rowid
+	`, orderID)
+	if err != nil {
+		return false, fmt.Errorf("error fetching order items: %w", err)
+	}
+
+	// Lines stored before their prices were kept have a price of 0, so their
+	// share of the total is unknown and only the whole order can be refunded.
+	if len(request.Items) > 0 {
+		for _, line := range lines {
+			if line.PricePerUnit == 0 {
+				return false, fmt.Errorf("%w: refund the whole order instead", ErrUnpricedOrder)
+			}
+		}
+	}
+
+	refundLines := request.Items
+	if len(refundLines) == 0 {
+		for i, line := range lines {
+			if line.Refunded < line.Quantity {
+				refundLines = append(refundLines, model.RefundLine{Line: i})
+			}
+		}
+	}
+
+	// Discounts are shared between lines in proportion to their price.
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.PricePerUnit * float64(line.Quantity)
+	}
+	share := 0.0
+	if subtotal > 0 {
+		share = total / subtotal
+	}
+
+	var amount float64
+	refundedUnits := make(map[int]int)
+	for i := range refundLines {
+		line := &refundLines[i]
+		if line.Line < 0 || line.Line >= len(lines) {
+			return false, fmt.Errorf("%w: order has no line %d", ErrInvalidRefund, line.Line)
+		}
+		if line.Quantity < 0 {
+			return false, fmt.Errorf("%w: quantity of line %d cannot be negative", ErrInvalidRefund, line.Line)
+		}
+
+		item := lines[line.Line]
+		left := item.Quantity - item.Refunded - refundedUnits[line.Line]
+		if line.Quantity == 0 {
+			line.Quantity = left
+		}
+		if line.Quantity == 0 || line.Quantity > left {
+			return false, fmt.Errorf("%w: line %d has %d units left to refund", ErrInvalidRefund, line.Line, left)
+		}
+		refundedUnits[line.Line] += line.Quantity
+
+		line.Amount = roundCents(item.PricePerUnit * float64(line.Quantity) * share)
+		amount += line.Amount
+	}
+
+	settled := true
+	for i, line := range lines {
+		if line.Refunded+refundedUnits[i] < line.Quantity {
+			settled = false
+		}
+	}
+
+	// The last units of an order take whatever is left of its total, so
+	// rounding never leaves part of it behind or refunds more than it.
+	remaining := roundCents(total - refunded)
+	if settled || amount > remaining {
+		if len(refundLines) > 0 {
+			last := &refundLines[len(refundLines)-1]
+			last.Amount = roundCents(last.Amount + remaining - amount)
+		}
+		amount = remaining
+	}
+	if len(refundLines) == 0 && amount <= 0 {
+		return settled, ErrNothingToRefund
+	}
+
+	refundID := uuid.New().String()
+	query := `INSERT INTO refunds (id, order_id, amount, reason, actor) VALUES (?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, refundID, orderID, amount, request.Reason, request.Actor); err != nil {
+		return false, fmt.Errorf("error recording refund: %w", err)
+	}
+
+	for _, line := range refundLines {
+		query := `
+			INSERT INTO refund_items (id, refund_id, order_item_id, quantity, amount)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		_, err := tx.Exec(query, uuid.New().String(), refundID, lines[line.Line].ID, line.Quantity, line.Amount)
+		if err != nil {
+			return false, fmt.Errorf("error recording refund item: %w", err)
+		}
+	}
+
+	return settled, nil
+}
+
+// loadRefunds loads the refunds of the given orders, keyed by order ID.
+// lineOf maps order item IDs to their index in their order's items.
+func (r *OrderRepository) loadRefunds(orderIDs []string, lineOf map[string]int) (map[string][]model.Refund, error) {
+	query, args, err := sqlx.In(`
+		SELECT * FROM refunds
+		WHERE order_id IN (?)
+		ORDER BY created_at, rowid
+	`, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error building refunds query: %w", err)
+	}
+
+	var refundsDB []RefundDB
+	if err := r.db.Select(&refundsDB, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+
+	refunds := make(map[string][]model.Refund)
+	if len(refundsDB) == 0 {
+		return refunds, nil
+	}
+
+	refundIDs := make([]string, len(refundsDB))
+	for i, refund := range refundsDB {
+		refundIDs[i] = refund.ID
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT * FROM refund_items
+		WHERE refund_id IN (?)
+		ORDER BY rowid
+	`, refundIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error building refund items query: %w", err)
+	}
+
+	var itemsDB []RefundItemDB
+	if err := r.db.Select(&itemsDB, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("error fetching refund items: %w", err)
+	}
+
+	itemsByRefundID := make(map[string][]model.RefundLine)
+	for _, item := range itemsDB {
+		itemsByRefundID[item.RefundID] = append(itemsByRefundID[item.RefundID], model.RefundLine{
+			Line:     lineOf[item.OrderItemID],
+			Quantity: item.Quantity,
+			Amount:   item.Amount,
+		})
+	}
+
+	for _, refund := range refundsDB {
+		refunds[refund.OrderID] = append(refunds[refund.OrderID], model.Refund{
+			ID:        refund.ID,
+			Amount:    refund.Amount,
+			Reason:    refund.Reason,
+			Actor:     refund.Actor,
+			Items:     itemsByRefundID[refund.ID],
+			CreatedAt: refund.CreatedAt,
+		})
+	}
+	return refunds, nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/database"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// newTestDB returns an empty in-memory database with the current schema.
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	err := database.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_txlock=immediate&_foreign_keys=1", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.DB
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// placeTestOrder places an order for the given units and prices with a total
+// of total, redeeming coupon for the difference unless it is empty.
+func placeTestOrder(t *testing.T, db *sqlx.DB, total float64, coupon string, lines ...model.OrderItem) *model.Order {
+	t.Helper()
+	order := &model.Order{Total: total}
+	for _, line := range lines {
+		line.ProductID = uuid.New().String()
+		_, err := db.Exec(`INSERT INTO products (id, name, price, category) VALUES (?, ?, ?, 'Test')`,
+			line.ProductID, "Test product", line.UnitPrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order.Items = append(order.Items, line)
+		order.Products = append(order.Products, model.Product{ID: line.ProductID, Price: line.UnitPrice})
+		order.Discounts += line.UnitPrice * float64(line.Quantity)
+	}
+	order.Discounts = roundCents(order.Discounts - total)
+
+	var redemptions []CouponRedemption
+	if coupon != "" {
+		redemptions = append(redemptions, CouponRedemption{Code: coupon, DiscountType: "fixed", Discount: order.Discounts})
+	}
+	placed, err := NewOrderRepository(db).Create(order, redemptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return placed
+}
+
+func activeRedemptions(t *testing.T, db *sqlx.DB, code string) int {
+	t.Helper()
+	total, _, err := NewOrderRepository(db).CouponUsage(code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestRefund(t *testing.T) {
+	type step struct {
+		request    model.RefundRequest
+		wantAmount float64
+		wantLines  []float64
+		wantErr    error
+	}
+	tests := []struct {
+		name  string
+		total float64
+		lines []model.OrderItem
+		steps []step
+	}{
+		{
+			// 30 of items for 20: every unit refunds 6.67 until the last,
+			// which takes the 6.66 left.
+			name:  "units share the discount and the last takes the rest",
+			total: 20,
+			lines: []model.OrderItem{{Quantity: 3, UnitPrice: 10}},
+			steps: []step{
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}}}, wantAmount: 6.67, wantLines: []float64{6.67}},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}}}, wantAmount: 6.67, wantLines: []float64{6.67}},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}}}, wantAmount: 6.66, wantLines: []float64{6.66}},
+				{request: model.RefundRequest{}, wantErr: ErrNothingToRefund},
+			},
+		},
+		{
+			name:  "lines share the discount in proportion to their price",
+			total: 9,
+			lines: []model.OrderItem{{Quantity: 1, UnitPrice: 3}, {Quantity: 1, UnitPrice: 7}},
+			steps: []step{
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0}}}, wantAmount: 2.7, wantLines: []float64{2.7}},
+				{request: model.RefundRequest{}, wantAmount: 6.3, wantLines: []float64{6.3}},
+			},
+		},
+		{
+			name:  "whole order at once",
+			total: 11.1,
+			lines: []model.OrderItem{{Quantity: 2, UnitPrice: 2.35}, {Quantity: 1, UnitPrice: 7.4}},
+			steps: []step{
+				{request: model.RefundRequest{Reason: "cold"}, wantAmount: 11.1, wantLines: []float64{4.31, 6.79}},
+			},
+		},
+		{
+			name:  "one line in several requests",
+			total: 10,
+			lines: []model.OrderItem{{Quantity: 3, UnitPrice: 5}},
+			steps: []step{
+				{
+					request:    model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}, {Line: 0, Quantity: 1}}},
+					wantAmount: 6.66, wantLines: []float64{3.33, 3.33},
+				},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 2}}}, wantErr: ErrInvalidRefund},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0}}}, wantAmount: 3.34, wantLines: []float64{3.34}},
+			},
+		},
+		{
+			name:  "invalid lines",
+			total: 10,
+			lines: []model.OrderItem{{Quantity: 1, UnitPrice: 10}},
+			steps: []step{
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 1}}}, wantErr: ErrInvalidRefund},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: -1}}}, wantErr: ErrInvalidRefund},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: -1}}}, wantErr: ErrInvalidRefund},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 2}}}, wantErr: ErrInvalidRefund},
+				{request: model.RefundRequest{Items: []model.RefundLine{{Line: 0}}}, wantAmount: 10, wantLines: []float64{10}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			repo := NewOrderRepository(db)
+			order := placeTestOrder(t, db, tt.total, "REFUNDTEST", tt.lines...)
+
+			var refunded float64
+			for i, step := range tt.steps {
+				got, err := repo.Refund(order.ID, step.request)
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: Refund error = %v, want %v", i, err, step.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+
+				refund := got.Refunds[len(got.Refunds)-1]
+				if refund.Amount != step.wantAmount {
+					t.Errorf("step %d: refunded %v, want %v", i, refund.Amount, step.wantAmount)
+				}
+				var lines []float64
+				for _, line := range refund.Items {
+					lines = append(lines, line.Amount)
+				}
+				if fmt.Sprint(lines) != fmt.Sprint(step.wantLines) {
+					t.Errorf("step %d: line amounts %v, want %v", i, lines, step.wantLines)
+				}
+
+				refunded = roundCents(refunded + step.wantAmount)
+				if got.Refunded != refunded {
+					t.Errorf("step %d: order refunded %v, want %v", i, got.Refunded, refunded)
+				}
+			}
+		})
+	}
+}
+
+func TestRefundSettlesCoupons(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+	order := placeTestOrder(t, db, 18, "SETTLETEST", model.OrderItem{Quantity: 2, UnitPrice: 10})
+
+	partial := model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}}}
+	if _, err := repo.Refund(order.ID, partial); err != nil {
+		t.Fatal(err)
+	}
+	if n := activeRedemptions(t, db, "SETTLETEST"); n != 1 {
+		t.Fatalf("%d redemptions count after a partial refund, want 1", n)
+	}
+
+	got, err := repo.Refund(order.ID, model.RefundRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Refunded != order.Total {
+		t.Errorf("order refunded %v, want its total %v", got.Refunded, order.Total)
+	}
+	if n := activeRedemptions(t, db, "SETTLETEST"); n != 0 {
+		t.Errorf("%d redemptions count after a full refund, want 0", n)
+	}
+	if len(got.AppliedDiscounts) != 1 {
+		t.Errorf("order shows %d discounts after a full refund, want 1", len(got.AppliedDiscounts))
+	}
+}
+
+func TestRefundRejectedOrders(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+
+	got, err := repo.Refund("missing", model.RefundRequest{})
+	if got != nil || err != nil {
+		t.Errorf("Refund of a missing order = %v, %v, want nil, nil", got, err)
+	}
+
+	for _, status := range []string{model.OrderCancelled, model.OrderRejected} {
+		order := placeTestOrder(t, db, 5, "", model.OrderItem{Quantity: 1, UnitPrice: 5})
+		if _, err := db.Exec(`UPDATE orders SET status = ? WHERE id = ?`, status, order.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Refund(order.ID, model.RefundRequest{}); !errors.Is(err, ErrOrderNotRefundable) {
+			t.Errorf("Refund of a %s order error = %v, want ErrOrderNotRefundable", status, err)
+		}
+	}
+}
+
+func TestRefundUnpricedLines(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+
+	// Orders placed before line prices were kept have a price of 0.
+	order := placeTestOrder(t, db, 11.7, "", model.OrderItem{Quantity: 2, UnitPrice: 5}, model.OrderItem{Quantity: 1, UnitPrice: 3})
+	if _, err := db.Exec(`UPDATE order_items SET price_per_unit = 0 WHERE order_id = ?`, order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	partial := model.RefundRequest{Items: []model.RefundLine{{Line: 0, Quantity: 1}}}
+	if _, err := repo.Refund(order.ID, partial); !errors.Is(err, ErrUnpricedOrder) {
+		t.Fatalf("partial Refund error = %v, want ErrUnpricedOrder", err)
+	}
+
+	got, err := repo.Refund(order.ID, model.RefundRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Refunded != 11.7 {
+		t.Errorf("order refunded %v, want its total 11.7", got.Refunded)
+	}
+}
+
+func TestRoundCents(t *testing.T) {
+	tests := []struct {
+		amount, want float64
+	}{
+		{0, 0},
+		{6.666, 6.67},
+		{6.664, 6.66},
+		{2.675, 2.68},
+		{0.1 + 0.2, 0.3},
+		{-1.006, -1.01},
+	}
+	for _, tt := range tests {
+		if got := roundCents(tt.amount); got != tt.want {
+			t.Errorf("roundCents(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
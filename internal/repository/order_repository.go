@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,6 +12,13 @@ import (
 	"github.com/ravip18596/order-food-online/internal/model"
 )
 
+var (
+	ErrCouponNotActive        = errors.New("coupon is not active at this time")
+	ErrCouponExhausted        = errors.New("coupon has reached its redemption limit")
+	ErrCouponCustomerLimit    = errors.New("coupon has reached its per-customer redemption limit")
+	ErrCouponCustomerRequired = errors.New("coupon requires a verified customer id")
+)
+
 type OrderRepository struct {
 	db *sqlx.DB
 }
@@ -20,14 +28,39 @@ func NewOrderRepository(db *sqlx.DB) *OrderRepository {
 }
 
 type OrderDB struct {
-	ID         string    `db:"id"`
-	Total      float64   `db:"total"`
-	Discounts  float64   `db:"discounts"`
+	ID        string  `db:"id"`
+	Status    string  `db:"status"`
+	Total     float64 `db:"total"`
+	Discounts float64 `db:"discounts"`
+	// CouponCode is left NULL by Create, which records every code of an order
+	// in coupon_redemptions instead.
 	CouponCode *string   `db:"coupon_code"`
+	CustomerID *string   `db:"customer_id"`
 	CreatedAt  time.Time `db:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at"`
 }
 
+type CouponRedemptionDB struct {
+	ID           string     `db:"id"`
+	OrderID      string     `db:"order_id"`
+	CouponCode   string     `db:"coupon_code"`
+	CustomerID   *string    `db:"customer_id"`
+	DiscountType string     `db:"discount_type"`
+	Discount     float64    `db:"discount"`
+	RedeemedAt   time.Time  `db:"redeemed_at"`
+	ReleasedAt   *time.Time `db:"released_at"`
+}
+
+type OrderItemModifierDB struct {
+	ID          string  `db:"id"`
+	OrderItemID string  `db:"order_item_id"`
+	ModifierID  string  `db:"modifier_id"`
+	GroupName   string  `db:"group_name"`
+	Name        string  `db:"name"`
+	PriceDelta  float64 `db:"price_delta"`
+	Position    int     `db:"position"`
+}
+
 type OrderItemDB struct {
 	ID           string    `db:"id"`
 	OrderID      string    `db:"order_id"`
@@ -35,9 +68,61 @@ type OrderItemDB struct {
 	Quantity     int       `db:"quantity"`
 	PricePerUnit float64   `db:"price_per_unit"`
 	CreatedAt    time.Time `db:"created_at"`
+
+	// The product snapshot; NULL for items stored before snapshots were
+	// kept.
+	ProductName    *string  `db:"product_name"`
+	ProductPrice   *float64 `db:"product_price"`
+	Category       *string  `db:"category"`
+	ImageThumbnail *string  `db:"image_thumbnail"`
+	ImageMobile    *string  `db:"image_mobile"`
+	ImageTablet    *string  `db:"image_tablet"`
+	ImageDesktop   *string  `db:"image_desktop"`
 }
 
-func (r *OrderRepository) Create(order *model.Order) (*model.Order, error) {
+// snapshot returns the product as it was when the item was ordered. Items
+// without a snapshot only know their product's ID.
+func (item OrderItemDB) snapshot() model.Product {
+	product := model.Product{
+		ID:       item.ProductID,
+		Name:     stringValue(item.ProductName),
+		Category: stringValue(item.Category),
+		Image: model.Image{
+			Thumbnail: stringValue(item.ImageThumbnail),
+			Mobile:    stringValue(item.ImageMobile),
+			Tablet:    stringValue(item.ImageTablet),
+			Desktop:   stringValue(item.ImageDesktop),
+		},
+	}
+	if item.ProductPrice != nil {
+		product.Price = *item.ProductPrice
+	}
+	return product
+}
+
+// CouponRedemption is a coupon applied to an order together with the usage
+// limits it is subject to. Zero limits and nil bounds are not enforced.
+// CustomerID is the verified customer the redemption counts against, empty
+// if the customer is unknown or only claimed by the request; codes with a
+// per-customer limit need one.
+type CouponRedemption struct {
+	Code           string
+	CustomerID     string
+	DiscountType   string
+	Discount       float64
+	MaxRedemptions int
+	MaxPerCustomer int
+	StartsAt       *time.Time
+	EndsAt         *time.Time
+}
+
+// Create stores the order as pending, records its coupon redemptions and
+// takes its items out of stock. Each item is stored with its unit price and a
+// snapshot of its product, order.Products[i] being the product of
+// order.Items[i]. Coupon limits and stock levels are checked inside the same
+// transaction, after they are written, so concurrent orders cannot both take
+// the last available use or unit.
+func (r *OrderRepository) Create(order *model.Order, redemptions ...CouponRedemption) (_ *model.Order, err error) {
 	tx, err := r.db.Beginx()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %w", err)
@@ -59,33 +144,78 @@ func (r *OrderRepository) Create(order *model.Order) (*model.Order, error) {
 		order.ID = uuid.New().String()
 	}
 
+	order.Status = model.OrderPending
+
 	// Insert order
 	query := `
-		INSERT INTO orders (id, total, discounts)
-		VALUES (?, ?, ?)
+		INSERT INTO orders (id, status, total, discounts, customer_id)
+		VALUES (?, ?, ?, ?, ?)
 	`
 
-	_, err = tx.Exec(query, order.ID, order.Total, order.Discounts)
+	_, err = tx.Exec(query, order.ID, order.Status, order.Total, order.Discounts,
+		nullString(order.CustomerID))
 	if err != nil {
 		return nil, fmt.Errorf("error creating order: %w", err)
 	}
+	if err = tx.Get(&order.CreatedAt, `SELECT created_at FROM orders WHERE id = ?`, order.ID); err != nil {
+		return nil, fmt.Errorf("error fetching order: %w", err)
+	}
+
+	placed := model.OrderStatusUpdate{Status: order.Status, Actor: order.CustomerID}
+	if err = recordStatus(tx, order.ID, nil, placed); err != nil {
+		return nil, err
+	}
+
+	for _, redemption := range redemptions {
+		if err = redeemCoupon(tx, order, redemption); err != nil {
+			return nil, err
+		}
+	}
 
 	// Insert order items
-	for _, item := range order.Items {
+	for i, item := range order.Items {
+		product := order.Products[i]
 		itemID := uuid.New().String()
 		query = `
-			INSERT INTO order_items (id, order_id, product_id, quantity, price_per_unit)
-			VALUES (?, ?, ?, ?, ?)
+			INSERT INTO order_items (
+				id, order_id, product_id, quantity, price_per_unit,
+				product_name, product_price, category,
+				image_thumbnail, image_mobile, image_tablet, image_desktop
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
-		_, err = tx.Exec(query, itemID, order.ID, item.ProductID, item.Quantity, 0) // TODO: Get actual price
+		_, err = tx.Exec(query, itemID, order.ID, item.ProductID, item.Quantity, item.UnitPrice,
+			product.Name, product.Price, product.Category,
+			product.Image.Thumbnail, product.Image.Mobile, product.Image.Tablet, product.Image.Desktop)
 		if err != nil {
 			return nil, fmt.Errorf("error creating order item: %w", err)
 		}
+
+		for i, modifierID := range item.Modifiers {
+			if err = addItemModifier(tx, itemID, modifierID, i); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Take stock once per product so the check covers all of its lines
+	quantities := make(map[string]int)
+	var productIDs []string
+	for _, item := range order.Items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	for _, productID := range productIDs {
+		if err = takeStock(tx, productID, quantities[productID]); err != nil {
+			return nil, err
+		}
 	}
 
 	return order, nil
 }
 
+// GetByID returns the order with the given ID, or nil if there is none.
 func (r *OrderRepository) GetByID(id string) (*model.Order, error) {
 	var orderDB OrderDB
 	query := `SELECT * FROM orders WHERE id = ?`
@@ -97,38 +227,143 @@ func (r *OrderRepository) GetByID(id string) (*model.Order, error) {
 		return nil, fmt.Errorf("error fetching order: %w", err)
 	}
 
-	var itemsDB []OrderItemDB
-	query = `SELECT * FROM order_items WHERE order_id = ?`
-	err = r.db.Select(&itemsDB, query, id)
+	orders, err := r.withDetails([]OrderDB{orderDB})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching order items: %w", err)
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// orderSortCreatedAt is the only order listing sort, newest first. Like the
+// product created_at sort, it compares timestamps through datetime().
+const orderSortCreatedAt = "created_at"
+
+// OrderFilter selects a page of orders. Zero fields do not filter.
+type OrderFilter struct {
+	// Statuses keeps orders in any of the given statuses.
+	Statuses []string
+	// CouponCode keeps orders that redeemed the code.
+	CouponCode string
+	// ProductID keeps orders with an item of the product.
+	ProductID string
+	// CreatedFrom and CreatedTo bound when the order was placed; CreatedTo
+	// is exclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Limit       int
+	Cursor      string
+}
+
+// OrderPage is one page of an order listing. NextCursor is empty on the last
+// page; Total counts every order matching the filter.
+type OrderPage struct {
+	Orders     []model.Order
+	NextCursor string
+	Total      int
+}
+
+// List returns a page of orders matching filter, newest first, using keyset
+// pagination on the creation time and order ID. Only the page's items are
+// loaded.
+func (r *OrderRepository) List(filter OrderFilter) (*OrderPage, error) {
+	var where []string
+	var args []interface{}
+	if len(filter.Statuses) > 0 {
+		for _, status := range filter.Statuses {
+			if _, ok := transitions[status]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+			}
+		}
+		cond, condArgs, err := sqlx.In(`status IN (?)`, filter.Statuses)
+		if err != nil {
+			return nil, fmt.Errorf("error building status filter: %w", err)
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	if filter.CouponCode != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM coupon_redemptions
+			WHERE coupon_redemptions.order_id = orders.id AND coupon_redemptions.coupon_code = ?
+		)`)
+		args = append(args, filter.CouponCode)
+	}
+	if filter.ProductID != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM order_items
+			WHERE order_items.order_id = orders.id AND order_items.product_id = ?
+		)`)
+		args = append(args, filter.ProductID)
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, "datetime(created_at) >= ?")
+		args = append(args, sqliteTime(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, "datetime(created_at) < ?")
+		args = append(args, sqliteTime(*filter.CreatedTo))
 	}
 
-	order := &model.Order{
-		ID:        orderDB.ID,
-		Total:     orderDB.Total,
-		Discounts: orderDB.Discounts,
-		Items:     make([]model.OrderItem, len(itemsDB)),
+	conditions := func() string {
+		if len(where) == 0 {
+			return ""
+		}
+		return " WHERE " + strings.Join(where, " AND ")
 	}
 
-	for i, item := range itemsDB {
-		order.Items[i] = model.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
+	page := &OrderPage{}
+	if err := r.db.Get(&page.Total, `SELECT COUNT(*) FROM orders`+conditions(), args...); err != nil {
+		return nil, fmt.Errorf("error counting orders: %w", err)
+	}
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor, orderSortCreatedAt, true)
+		if err != nil {
+			return nil, err
 		}
+		cond, condArgs := keysetCondition("datetime(created_at)", c)
+		where = append(where, cond)
+		args = append(args, condArgs...)
 	}
 
-	return order, nil
-}
+	query := `SELECT * FROM orders` + conditions() + ` ORDER BY datetime(created_at) DESC, id DESC LIMIT ?`
+	args = append(args, filter.Limit+1)
 
-func (r *OrderRepository) List() ([]model.Order, error) {
 	var ordersDB []OrderDB
-	query := `SELECT * FROM orders ORDER BY created_at DESC`
-	err := r.db.Select(&ordersDB, query)
-	if err != nil {
+	if err := r.db.Select(&ordersDB, query, args...); err != nil {
 		return nil, fmt.Errorf("error fetching orders: %w", err)
 	}
 
+	if len(ordersDB) > filter.Limit {
+		ordersDB = ordersDB[:filter.Limit]
+		last := ordersDB[len(ordersDB)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:  orderSortCreatedAt,
+			Desc:  true,
+			Value: sqliteTime(last.CreatedAt),
+			ID:    last.ID,
+		})
+	}
+
+	orders, err := r.withDetails(ordersDB)
+	if err != nil {
+		return nil, err
+	}
+	page.Orders = orders
+	return page, nil
+}
+
+// sqliteTimeLayout is the format of datetime() results.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// sqliteTime formats t the way datetime() does, for comparisons with it.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// withDetails converts orders to their API form, loading their items,
+// product snapshots, applied discounts and refunds in one batch.
+func (r *OrderRepository) withDetails(ordersDB []OrderDB) ([]model.Order, error) {
 	if len(ordersDB) == 0 {
 		return []model.Order{}, nil
 	}
@@ -141,9 +376,9 @@ func (r *OrderRepository) List() ([]model.Order, error) {
 
 	var itemsDB []OrderItemDB
 	query, args, err := sqlx.In(`
-		SELECT * FROM order_items 
-		WHERE order_id IN (?) 
-		ORDER BY created_at
+		SELECT * FROM order_items
+		WHERE order_id IN (?)
+		ORDER BY rowid
 	`, orderIDs)
 
 	if err != nil {
@@ -156,23 +391,247 @@ func (r *OrderRepository) List() ([]model.Order, error) {
 		return nil, fmt.Errorf("error fetching order items: %w", err)
 	}
 
+	modifiers, err := r.itemModifiers(itemsDB)
+	if err != nil {
+		return nil, err
+	}
+
 	itemsByOrderID := make(map[string][]model.OrderItem)
+	productsByOrderID := make(map[string][]model.Product)
+	lineOf := make(map[string]int)
 	for _, item := range itemsDB {
+		lineOf[item.ID] = len(itemsByOrderID[item.OrderID])
 		itemsByOrderID[item.OrderID] = append(itemsByOrderID[item.OrderID], model.OrderItem{
 			ProductID: item.ProductID,
 			Quantity:  item.Quantity,
+			Modifiers: modifiers[item.ID],
+			UnitPrice: item.PricePerUnit,
 		})
+		productsByOrderID[item.OrderID] = append(productsByOrderID[item.OrderID], item.snapshot())
+	}
+
+	discounts, err := r.appliedDiscounts(orderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds, err := r.loadRefunds(orderIDs, lineOf)
+	if err != nil {
+		return nil, err
 	}
 
 	orders := make([]model.Order, len(ordersDB))
 	for i, orderDB := range ordersDB {
 		order := model.Order{
-			ID:        orderDB.ID,
-			Total:     orderDB.Total,
-			Discounts: orderDB.Discounts,
-			Items:     itemsByOrderID[orderDB.ID],
+			ID:               orderDB.ID,
+			Status:           orderDB.Status,
+			Total:            orderDB.Total,
+			Discounts:        orderDB.Discounts,
+			CustomerID:       stringValue(orderDB.CustomerID),
+			AppliedDiscounts: discounts[orderDB.ID],
+			Items:            itemsByOrderID[orderDB.ID],
+			Products:         productsByOrderID[orderDB.ID],
+			Refunds:          refunds[orderDB.ID],
+			CreatedAt:        orderDB.CreatedAt,
+		}
+		for _, refund := range order.Refunds {
+			order.Refunded += refund.Amount
 		}
+		order.Refunded = roundCents(order.Refunded)
 		orders[i] = order
 	}
 	return orders, nil
 }
+
+// appliedDiscounts loads the coupon discounts of the given orders, keyed by
+// order ID.
+func (r *OrderRepository) appliedDiscounts(orderIDs []string) (map[string][]model.AppliedDiscount, error) {
+	query, args, err := sqlx.In(`
+		SELECT * FROM coupon_redemptions
+		WHERE order_id IN (?)
+		ORDER BY coupon_code
+	`, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error building coupon redemptions query: %w", err)
+	}
+
+	var redemptionsDB []CouponRedemptionDB
+	if err := r.db.Select(&redemptionsDB, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("error fetching coupon redemptions: %w", err)
+	}
+
+	discounts := make(map[string][]model.AppliedDiscount)
+	for _, redemption := range redemptionsDB {
+		discounts[redemption.OrderID] = append(discounts[redemption.OrderID], model.AppliedDiscount{
+			Code:   redemption.CouponCode,
+			Type:   redemption.DiscountType,
+			Amount: redemption.Discount,
+		})
+	}
+	return discounts, nil
+}
+
+// itemModifiers loads the IDs of the modifiers chosen for the given order
+// items, keyed by order item ID.
+func (r *OrderRepository) itemModifiers(items []OrderItemDB) (map[string][]string, error) {
+	modifiers := make(map[string][]string)
+	if len(items) == 0 {
+		return modifiers, nil
+	}
+
+	itemIDs := make([]string, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM order_item_modifiers
+		WHERE order_item_id IN (?)
+		ORDER BY position
+	`, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error building order item modifiers query: %w", err)
+	}
+
+	var modifiersDB []OrderItemModifierDB
+	if err := r.db.Select(&modifiersDB, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("error fetching order item modifiers: %w", err)
+	}
+
+	for _, modifier := range modifiersDB {
+		modifiers[modifier.OrderItemID] = append(modifiers[modifier.OrderItemID], modifier.ModifierID)
+	}
+	return modifiers, nil
+}
+
+// addItemModifier records a modifier chosen for an order item, copying its
+// name and price so later catalog edits do not change the order.
+func addItemModifier(tx *sqlx.Tx, itemID, modifierID string, position int) error {
+	query := `
+		INSERT INTO order_item_modifiers (id, order_item_id, modifier_id, group_name, name, price_delta, position)
+		SELECT ?, ?, modifiers.id, modifier_groups.name, modifiers.name, modifiers.price_delta, ?
+		FROM modifiers JOIN modifier_groups ON modifier_groups.id = modifiers.group_id
+		WHERE modifiers.id = ?
+	`
+	res, err := tx.Exec(query, uuid.New().String(), itemID, position, modifierID)
+	if err != nil {
+		return fmt.Errorf("error recording order item modifier: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error recording order item modifier: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("modifier %s no longer exists", modifierID)
+	}
+	return nil
+}
+
+// CouponUsage returns how many orders have redeemed code in total and, when
+// customerID is not empty, how many of those belong to that customer.
+// Redemptions released by cancelled or fully refunded orders do not count.
+func (r *OrderRepository) CouponUsage(code, customerID string) (total int, customer int, err error) {
+	query := `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_code = ? AND released_at IS NULL`
+	if err = r.db.Get(&total, query, code); err != nil {
+		return 0, 0, fmt.Errorf("error counting coupon redemptions: %w", err)
+	}
+
+	if customerID != "" {
+		query = `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_code = ? AND customer_id = ? AND released_at IS NULL`
+		if err = r.db.Get(&customer, query, code, customerID); err != nil {
+			return 0, 0, fmt.Errorf("error counting coupon redemptions: %w", err)
+		}
+	}
+
+	return total, customer, nil
+}
+
+// ImageInUse reports whether an order item's product snapshot shows the
+// image with the given thumbnail URL.
+func (r *OrderRepository) ImageInUse(thumbnail string) (bool, error) {
+	var inUse bool
+	query := `SELECT EXISTS (SELECT 1 FROM order_items WHERE image_thumbnail = ?)`
+	if err := r.db.Get(&inUse, query, thumbnail); err != nil {
+		return false, fmt.Errorf("error checking image use: %w", err)
+	}
+	return inUse, nil
+}
+
+// redeemCoupon records a coupon redemption for order and verifies that it
+// stays within the coupon's limits.
+func redeemCoupon(tx *sqlx.Tx, order *model.Order, redemption CouponRedemption) error {
+	now := time.Now()
+	if (redemption.StartsAt != nil && now.Before(*redemption.StartsAt)) ||
+		(redemption.EndsAt != nil && !now.Before(*redemption.EndsAt)) {
+		return ErrCouponNotActive
+	}
+	if redemption.MaxPerCustomer > 0 && redemption.CustomerID == "" {
+		return ErrCouponCustomerRequired
+	}
+
+	query := `
+		INSERT INTO coupon_redemptions (id, order_id, coupon_code, customer_id, discount_type, discount)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := tx.Exec(query, uuid.New().String(), order.ID, redemption.Code, nullString(redemption.CustomerID),
+		redemption.DiscountType, redemption.Discount)
+	if err != nil {
+		return fmt.Errorf("error recording coupon redemption: %w", err)
+	}
+
+	if redemption.MaxRedemptions > 0 {
+		var used int
+		err = tx.Get(&used, `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_code = ? AND released_at IS NULL`, redemption.Code)
+		if err != nil {
+			return fmt.Errorf("error counting coupon redemptions: %w", err)
+		}
+		if used > redemption.MaxRedemptions {
+			return ErrCouponExhausted
+		}
+	}
+
+	if redemption.MaxPerCustomer > 0 {
+		var used int
+		err = tx.Get(&used, `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_code = ? AND customer_id = ? AND released_at IS NULL`,
+			redemption.Code, redemption.CustomerID)
+		if err != nil {
+			return fmt.Errorf("error counting coupon redemptions: %w", err)
+		}
+		if used > redemption.MaxPerCustomer {
+			return ErrCouponCustomerLimit
+		}
+	}
+
+	return nil
+}
+
+// takeStock removes quantity units of a product from its stock, if tracked,
+// and fails with ErrInsufficientStock if that leaves less than none.
+func takeStock(tx *sqlx.Tx, productID string, quantity int) error {
+	_, err := tx.Exec(`UPDATE products SET stock = stock - ? WHERE id = ? AND stock IS NOT NULL`, quantity, productID)
+	if err != nil {
+		return fmt.Errorf("error updating stock: %w", err)
+	}
+
+	var stock *int
+	if err = tx.Get(&stock, `SELECT stock FROM products WHERE id = ?`, productID); err != nil {
+		return fmt.Errorf("error reading stock: %w", err)
+	}
+	if stock != nil && *stock < 0 {
+		return fmt.Errorf("%w: product %s has %d left", ErrInsufficientStock, productID, *stock+quantity)
+	}
+	return nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// placeCouponOrder places a one-item order of 10 redeeming redemption for 1.
+func placeCouponOrder(t *testing.T, db *sqlx.DB, redemption CouponRedemption) (*model.Order, error) {
+	t.Helper()
+	productID := uuid.New().String()
+	_, err := db.Exec(`INSERT INTO products (id, name, price, category) VALUES (?, 'Test product', 10, 'Test')`, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redemption.DiscountType = "fixed"
+	redemption.Discount = 1
+	order := &model.Order{
+		Total:      9,
+		Discounts:  1,
+		CustomerID: redemption.CustomerID,
+		Items:      []model.OrderItem{{ProductID: productID, Quantity: 1, UnitPrice: 10}},
+		Products:   []model.Product{{ID: productID, Price: 10}},
+	}
+	return NewOrderRepository(db).Create(order, redemption)
+}
+
+func TestCouponRedemptionLimits(t *testing.T) {
+	type step struct {
+		customer string
+		cancel   int // 1-based index of an earlier step whose order to cancel first
+		wantErr  error
+	}
+	tests := []struct {
+		name           string
+		maxRedemptions int
+		maxPerCustomer int
+		steps          []step
+	}{
+		{
+			name:           "total limit",
+			maxRedemptions: 2,
+			steps: []step{
+				{},
+				{customer: "alice"},
+				{wantErr: ErrCouponExhausted},
+				{cancel: 1},
+				{wantErr: ErrCouponExhausted},
+			},
+		},
+		{
+			name:           "per-customer limit",
+			maxPerCustomer: 1,
+			steps: []step{
+				{customer: "alice"},
+				{customer: "alice", wantErr: ErrCouponCustomerLimit},
+				{customer: "bob"},
+				{wantErr: ErrCouponCustomerRequired},
+				{customer: "alice", cancel: 1},
+				{customer: "alice", wantErr: ErrCouponCustomerLimit},
+			},
+		},
+		{
+			name:           "both limits",
+			maxRedemptions: 2,
+			maxPerCustomer: 1,
+			steps: []step{
+				{customer: "alice"},
+				{customer: "bob"},
+				{customer: "carol", wantErr: ErrCouponExhausted},
+				{customer: "carol", cancel: 2},
+				{customer: "alice", cancel: 4, wantErr: ErrCouponCustomerLimit},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			repo := NewOrderRepository(db)
+
+			orders := make([]*model.Order, len(tt.steps))
+			for i, step := range tt.steps {
+				if step.cancel > 0 {
+					cancel := model.OrderStatusUpdate{Status: model.OrderCancelled}
+					if _, err := repo.UpdateStatus(orders[step.cancel-1].ID, cancel); err != nil {
+						t.Fatalf("step %d: %v", i+1, err)
+					}
+				}
+
+				redemption := CouponRedemption{
+					Code:           "LIMITED",
+					CustomerID:     step.customer,
+					MaxRedemptions: tt.maxRedemptions,
+					MaxPerCustomer: tt.maxPerCustomer,
+				}
+				order, err := placeCouponOrder(t, db, redemption)
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: Create error = %v, want %v", i+1, err, step.wantErr)
+				}
+				orders[i] = order
+			}
+
+			// Rejected orders leave nothing behind.
+			var stored int
+			if err := db.Get(&stored, `SELECT COUNT(*) FROM orders`); err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			for _, order := range orders {
+				if order != nil {
+					want++
+				}
+			}
+			if stored != want {
+				t.Errorf("%d orders stored, want %d", stored, want)
+			}
+		})
+	}
+}
+
+func TestOrderStock(t *testing.T) {
+	stock := func(n int) *int { return &n }
+	type line struct {
+		product  int // index into stocks
+		quantity int
+	}
+	tests := []struct {
+		name    string
+		stocks  []*int
+		lines   []line
+		wantErr error
+		want    []*int
+	}{
+		{
+			name:   "enough stock",
+			stocks: []*int{stock(5)},
+			lines:  []line{{0, 3}},
+			want:   []*int{stock(2)},
+		},
+		{
+			name:   "last units",
+			stocks: []*int{stock(3)},
+			lines:  []line{{0, 3}},
+			want:   []*int{stock(0)},
+		},
+		{
+			name:    "more than the stock",
+			stocks:  []*int{stock(2)},
+			lines:   []line{{0, 3}},
+			wantErr: ErrInsufficientStock,
+			want:    []*int{stock(2)},
+		},
+		{
+			name:    "lines of one product add up",
+			stocks:  []*int{stock(3)},
+			lines:   []line{{0, 2}, {0, 2}},
+			wantErr: ErrInsufficientStock,
+			want:    []*int{stock(3)},
+		},
+		{
+			name:    "one short product rolls back the others",
+			stocks:  []*int{stock(5), stock(1)},
+			lines:   []line{{0, 2}, {1, 2}},
+			wantErr: ErrInsufficientStock,
+			want:    []*int{stock(5), stock(1)},
+		},
+		{
+			name:   "untracked stock is unlimited",
+			stocks: []*int{nil, stock(1)},
+			lines:  []line{{0, 1000}, {1, 1}},
+			want:   []*int{nil, stock(0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			products := NewProductRepository(db)
+
+			ids := make([]string, len(tt.stocks))
+			for i, stock := range tt.stocks {
+				product, err := products.Create(&model.Product{Name: "Waffle", Price: 4, Category: "Dessert", Stock: stock})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids[i] = product.ID
+			}
+
+			order := &model.Order{}
+			for _, line := range tt.lines {
+				order.Items = append(order.Items, model.OrderItem{ProductID: ids[line.product], Quantity: line.quantity, UnitPrice: 4})
+				order.Products = append(order.Products, model.Product{ID: ids[line.product], Price: 4})
+				order.Total += 4 * float64(line.quantity)
+			}
+			_, err := NewOrderRepository(db).Create(order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create error = %v, want %v", err, tt.wantErr)
+			}
+
+			for i, id := range ids {
+				product, err := products.GetByID(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := product.Stock, tt.want[i]; (got == nil) != (want == nil) || (got != nil && *got != *want) {
+					t.Errorf("product %d stock = %v, want %v", i, fmtStock(got), fmtStock(want))
+				}
+			}
+
+			var stored int
+			if err := db.Get(&stored, `SELECT COUNT(*) FROM order_items`); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil && stored != 0 {
+				t.Errorf("%d order items stored for a rejected order, want 0", stored)
+			}
+		})
+	}
+}
+
+func fmtStock(stock *int) string {
+	if stock == nil {
+		return "untracked"
+	}
+	return strconv.Itoa(*stock)
+}
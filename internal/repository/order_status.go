@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown order status")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+)
+
+// transitions lists the statuses each status can move to. Completed,
+// cancelled and rejected orders are final.
+var transitions = map[string][]string{
+	model.OrderPending:   {model.OrderConfirmed, model.OrderCancelled, model.OrderRejected},
+	model.OrderConfirmed: {model.OrderPreparing, model.OrderCancelled},
+	model.OrderPreparing: {model.OrderReady, model.OrderCancelled},
+	model.OrderReady:     {model.OrderCompleted},
+	model.OrderCompleted: nil,
+	model.OrderCancelled: nil,
+	model.OrderRejected:  nil,
+}
+
+type OrderStatusHistoryDB struct {
+	ID         int64     `db:"id"`
+	OrderID    string    `db:"order_id"`
+	FromStatus *string   `db:"from_status"`
+	ToStatus   string    `db:"to_status"`
+	Actor      string    `db:"actor"`
+	Note       string    `db:"note"`
+	ChangedAt  time.Time `db:"changed_at"`
+}
+
+// canTransition reports whether an order can move from one status to another.
+func canTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateStatus moves the order to the given status and records the change.
+// Cancelling or rejecting an order also releases it, see releaseOrder. It
+// returns nil if there is no such order.
+func (r *OrderRepository) UpdateStatus(id string, update model.OrderStatusUpdate) (*model.Order, error) {
+	if _, ok := transitions[update.Status]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, update.Status)
+	}
+
+	found := true
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		var err error
+		found, err = setStatus(tx, id, update)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// setStatus moves an order to a new status inside tx, reporting false if the
+// order does not exist.
+func setStatus(tx *sqlx.Tx, id string, update model.OrderStatusUpdate) (bool, error) {
+	var current string
+	err := tx.Get(&current, `SELECT status FROM orders WHERE id = ?`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error fetching order status: %w", err)
+	}
+
+	if !canTransition(current, update.Status) {
+		return true, fmt.Errorf("%w: order is %s and cannot become %s", ErrInvalidTransition, current, update.Status)
+	}
+
+	_, err = tx.Exec(`UPDATE orders SET status = ? WHERE id = ?`, update.Status, id)
+	if err != nil {
+		return true, fmt.Errorf("error updating order status: %w", err)
+	}
+
+	if update.Status == model.OrderCancelled || update.Status == model.OrderRejected {
+		if err := releaseOrder(tx, id, update); err != nil {
+			return true, err
+		}
+	}
+
+	return true, recordStatus(tx, id, &current, update)
+}
+
+// recordStatus adds an entry to the order's status history.
+func recordStatus(tx *sqlx.Tx, orderID string, from *string, update model.OrderStatusUpdate) error {
+	query := `
+		INSERT INTO order_status_history (order_id, from_status, to_status, actor, note)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := tx.Exec(query, orderID, from, update.Status, update.Actor, update.Note)
+	if err != nil {
+		return fmt.Errorf("error recording order status: %w", err)
+	}
+	return nil
+}
+
+// StatusHistory returns the status changes of the order, oldest first, or nil
+// if there is no such order.
+func (r *OrderRepository) StatusHistory(id string) ([]model.StatusChange, error) {
+	var exists bool
+	err := r.db.Get(&exists, `SELECT COUNT(*) > 0 FROM orders WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	var historyDB []OrderStatusHistoryDB
+	query := `SELECT * FROM order_status_history WHERE order_id = ? ORDER BY id`
+	if err := r.db.Select(&historyDB, query, id); err != nil {
+		return nil, fmt.Errorf("error fetching order status history: %w", err)
+	}
+
+	history := make([]model.StatusChange, len(historyDB))
+	for i, entry := range historyDB {
+		history[i] = model.StatusChange{
+			From:      stringValue(entry.FromStatus),
+			To:        entry.ToStatus,
+			Actor:     entry.Actor,
+			Note:      entry.Note,
+			ChangedAt: entry.ChangedAt,
+		}
+	}
+	return history, nil
+}
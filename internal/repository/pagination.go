@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position after the last row of a page in a keyset-paginated
+// listing. It records the sort it was issued for so that it cannot be replayed
+// against a different ordering.
+type cursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses s and checks that it belongs to the given sort and holds
+// a value of the sort column's type: a number for price, a timestamp for
+// created_at and a string otherwise.
+func decodeCursor(s, sort string, desc bool) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, ErrInvalidCursor
+	}
+
+	var valid bool
+	switch value := c.Value.(type) {
+	case float64:
+		valid = sort == ProductSortPrice
+	case string:
+		switch sort {
+		case ProductSortPrice:
+		case ProductSortCreatedAt: // and orderSortCreatedAt
+			_, err := time.Parse(sqliteTimeLayout, value)
+			valid = err == nil
+		default:
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// keysetCondition returns the WHERE clause selecting rows after c for a
+// listing ordered by column and then id.
+func keysetCondition(column string, c *cursor) (string, []interface{}) {
+	op := ">"
+	if c.Desc {
+		op = "<"
+	}
+	return "(" + column + " " + op + " ? OR (" + column + " = ? AND id " + op + " ?))",
+		[]interface{}{c.Value, c.Value, c.ID}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	raw := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		sort    string
+		desc    bool
+		wantErr bool
+	}{
+		{"name", encodeCursor(cursor{Sort: ProductSortName, Value: "Bagel", ID: "1"}), ProductSortName, false, false},
+		{"price", encodeCursor(cursor{Sort: ProductSortPrice, Value: 3.5, ID: "1"}), ProductSortPrice, false, false},
+		{"created_at", encodeCursor(cursor{Sort: ProductSortCreatedAt, Desc: true, Value: "2024-05-01 10:00:00", ID: "1"}),
+			ProductSortCreatedAt, true, false},
+		{"other sort", encodeCursor(cursor{Sort: ProductSortName, Value: "Bagel", ID: "1"}), ProductSortPrice, false, true},
+		{"other order", encodeCursor(cursor{Sort: ProductSortName, Value: "Bagel", ID: "1"}), ProductSortName, true, true},
+		{"number for name", raw(`{"s":"name","v":1,"id":"1"}`), ProductSortName, false, true},
+		{"string for price", raw(`{"s":"price","v":"3.5","id":"1"}`), ProductSortPrice, false, true},
+		{"number for created_at", raw(`{"s":"created_at","v":1714557600,"id":"1"}`), ProductSortCreatedAt, false, true},
+		{"malformed created_at", raw(`{"s":"created_at","v":"yesterday","id":"1"}`), ProductSortCreatedAt, false, true},
+		{"missing value", raw(`{"s":"name","id":"1"}`), ProductSortName, false, true},
+		{"object value", raw(`{"s":"name","v":{},"id":"1"}`), ProductSortName, false, true},
+		{"missing id", raw(`{"s":"name","v":"Bagel"}`), ProductSortName, false, true},
+		{"not JSON", raw(`name`), ProductSortName, false, true},
+		{"not base64", "!!", ProductSortName, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.cursor, tt.sort, tt.desc)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("decodeCursor error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
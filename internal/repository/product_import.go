@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// errImportRolledBack makes inTx discard an import that must not be kept.
+var errImportRolledBack = errors.New("import rolled back")
+
+// ImportResult is the outcome of importing one product.
+type ImportResult struct {
+	Created bool
+	Err     error
+}
+
+// Import creates or updates products in a single transaction. A product
+// whose ID exists is updated like Update does, restoring it if it was
+// deleted, and gets its stock set when Stock is not nil; any other product is
+// created. The transaction is committed only if commit is true and every
+// product was stored, which Import reports. Failures of individual products
+// are returned in their results.
+func (r *ProductRepository) Import(products []*model.Product, commit bool) ([]ImportResult, bool, error) {
+	results := make([]ImportResult, len(products))
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		failed := false
+		for i, product := range products {
+			results[i].Created, results[i].Err = upsertProduct(tx, product)
+			if results[i].Err != nil {
+				failed = true
+			}
+		}
+
+		if failed || !commit {
+			return errImportRolledBack
+		}
+		return nil
+	})
+
+	if errors.Is(err, errImportRolledBack) {
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// upsertProduct stores product, creating it unless its ID exists, and
+// reports whether it was created.
+func upsertProduct(tx *sqlx.Tx, product *model.Product) (bool, error) {
+	exists := false
+	if product.ID != "" {
+		if err := tx.Get(&exists, `SELECT COUNT(*) > 0 FROM products WHERE id = ?`, product.ID); err != nil {
+			return false, fmt.Errorf("error fetching product: %w", err)
+		}
+	}
+
+	if !exists {
+		return true, insertProduct(tx, product)
+	}
+
+	if _, err := tx.Exec(`UPDATE products SET deleted_at = NULL WHERE id = ?`, product.ID); err != nil {
+		return false, fmt.Errorf("error restoring product: %w", err)
+	}
+	if _, err := updateProduct(tx, product); err != nil {
+		return false, err
+	}
+	if product.Stock != nil {
+		if _, err := tx.Exec(`UPDATE products SET stock = ? WHERE id = ?`, *product.Stock, product.ID); err != nil {
+			return false, fmt.Errorf("error setting stock: %w", err)
+		}
+	}
+	return false, nil
+}
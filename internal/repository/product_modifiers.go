@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+var ErrModifierExists = errors.New("modifier or modifier group id is already used by another product")
+
+type ModifierGroupDB struct {
+	ID           string `db:"id"`
+	ProductID    string `db:"product_id"`
+	Name         string `db:"name"`
+	Required     bool   `db:"required"`
+	MinSelect    int    `db:"min_select"`
+	MaxSelect    int    `db:"max_select"`
+	DisplayOrder int    `db:"display_order"`
+}
+
+type ModifierDB struct {
+	ID           string  `db:"id"`
+	GroupID      string  `db:"group_id"`
+	Name         string  `db:"name"`
+	PriceDelta   float64 `db:"price_delta"`
+	DisplayOrder int     `db:"display_order"`
+}
+
+// saveModifierGroups replaces the modifier groups of a product with groups,
+// in the given order. Groups and modifiers without an ID are assigned one.
+func saveModifierGroups(tx *sqlx.Tx, productID string, groups []model.ModifierGroup) error {
+	_, err := tx.Exec(`
+		DELETE FROM modifiers
+		WHERE group_id IN (SELECT id FROM modifier_groups WHERE product_id = ?)
+	`, productID)
+	if err != nil {
+		return fmt.Errorf("error removing modifiers: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM modifier_groups WHERE product_id = ?`, productID); err != nil {
+		return fmt.Errorf("error removing modifier groups: %w", err)
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		if group.ID == "" {
+			group.ID = uuid.New().String()
+		}
+
+		query := `
+			INSERT INTO modifier_groups (id, product_id, name, required, min_select, max_select, display_order)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`
+		_, err := tx.Exec(query, group.ID, productID, group.Name, group.Required, group.MinSelect, group.MaxSelect, i)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrModifierExists
+			}
+			return fmt.Errorf("error creating modifier group: %w", err)
+		}
+
+		for j := range group.Modifiers {
+			modifier := &group.Modifiers[j]
+			if modifier.ID == "" {
+				modifier.ID = uuid.New().String()
+			}
+
+			query = `
+				INSERT INTO modifiers (id, group_id, name, price_delta, display_order)
+				VALUES (?, ?, ?, ?, ?)
+			`
+			_, err = tx.Exec(query, modifier.ID, group.ID, modifier.Name, modifier.PriceDelta, j)
+			if err != nil {
+				if isUniqueViolation(err) {
+					return ErrModifierExists
+				}
+				return fmt.Errorf("error creating modifier: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadModifierGroups fills in the modifier groups of products.
+func (r *ProductRepository) loadModifierGroups(products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	productIDs := make([]string, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM modifier_groups
+		WHERE product_id IN (?)
+		ORDER BY display_order
+	`, productIDs)
+	if err != nil {
+		return fmt.Errorf("error building modifier groups query: %w", err)
+	}
+
+	var groupsDB []ModifierGroupDB
+	if err := r.db.Select(&groupsDB, r.db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("error fetching modifier groups: %w", err)
+	}
+	if len(groupsDB) == 0 {
+		return nil
+	}
+
+	groupIDs := make([]string, len(groupsDB))
+	for i, group := range groupsDB {
+		groupIDs[i] = group.ID
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT * FROM modifiers
+		WHERE group_id IN (?)
+		ORDER BY display_order
+	`, groupIDs)
+	if err != nil {
+		return fmt.Errorf("error building modifiers query: %w", err)
+	}
+
+	var modifiersDB []ModifierDB
+	if err := r.db.Select(&modifiersDB, r.db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("error fetching modifiers: %w", err)
+	}
+
+	modifiersByGroupID := make(map[string][]model.Modifier)
+	for _, modifier := range modifiersDB {
+		modifiersByGroupID[modifier.GroupID] = append(modifiersByGroupID[modifier.GroupID], model.Modifier{
+			ID:         modifier.ID,
+			Name:       modifier.Name,
+			PriceDelta: modifier.PriceDelta,
+		})
+	}
+
+	groupsByProductID := make(map[string][]model.ModifierGroup)
+	for _, group := range groupsDB {
+		groupsByProductID[group.ProductID] = append(groupsByProductID[group.ProductID], model.ModifierGroup{
+			ID:        group.ID,
+			Name:      group.Name,
+			Required:  group.Required,
+			MinSelect: group.MinSelect,
+			MaxSelect: group.MaxSelect,
+			Modifiers: modifiersByGroupID[group.ID],
+		})
+	}
+
+	for _, product := range products {
+		product.ModifierGroups = groupsByProductID[product.ID]
+	}
+	return nil
+}
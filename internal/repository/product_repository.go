@@ -3,15 +3,28 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/ravip18596/order-food-online/internal/model"
 )
 
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrStockNotTracked   = errors.New("stock is not tracked for this product")
+)
+
 type ProductRepository struct {
 	db *sqlx.DB
+
+	ftsMu    sync.Mutex
+	fts      bool
+	ftsKnown bool
 }
 
 func NewProductRepository(db *sqlx.DB) *ProductRepository {
@@ -19,16 +32,39 @@ func NewProductRepository(db *sqlx.DB) *ProductRepository {
 }
 
 type ProductDB struct {
-	ID             string    `db:"id"`
-	Name           string    `db:"name"`
-	Price          float64   `db:"price"`
-	Category       string    `db:"category"`
-	ImageThumbnail string    `db:"image_thumbnail"`
-	ImageMobile    string    `db:"image_mobile"`
-	ImageTablet    string    `db:"image_tablet"`
-	ImageDesktop   string    `db:"image_desktop"`
-	CreatedAt      time.Time `db:"created_at"`
-	UpdatedAt      time.Time `db:"updated_at"`
+	ID             string     `db:"id"`
+	Name           string     `db:"name"`
+	Description    string     `db:"description"`
+	Price          float64    `db:"price"`
+	Category       string     `db:"category"`
+	CategoryID     *string    `db:"category_id"`
+	Stock          *int       `db:"stock"`
+	ImageThumbnail string     `db:"image_thumbnail"`
+	ImageMobile    string     `db:"image_mobile"`
+	ImageTablet    string     `db:"image_tablet"`
+	ImageDesktop   string     `db:"image_desktop"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+	DeletedAt      *time.Time `db:"deleted_at"`
+}
+
+func (p ProductDB) toModel() *model.Product {
+	return &model.Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Category:    p.Category,
+		CategoryID:  stringValue(p.CategoryID),
+		Image: model.Image{
+			Thumbnail: p.ImageThumbnail,
+			Mobile:    p.ImageMobile,
+			Tablet:    p.ImageTablet,
+			Desktop:   p.ImageDesktop,
+		},
+		DeletedAt: p.DeletedAt,
+		Stock:     p.Stock,
+	}
 }
 
 func (r *ProductRepository) Create(product *model.Product) (*model.Product, error) {
@@ -36,37 +72,62 @@ func (r *ProductRepository) Create(product *model.Product) (*model.Product, erro
 		return nil, errors.New("product cannot be nil")
 	}
 
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		return insertProduct(tx, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(product.ID)
+}
+
+// insertProduct stores a new product with its modifier groups and
+// availability windows.
+func insertProduct(tx *sqlx.Tx, product *model.Product) error {
 	// Generate new UUID if not provided
 	if product.ID == "" {
 		product.ID = uuid.New().String()
 	}
 
+	if err := resolveCategory(tx, product); err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO products (
-			id, name, price, category, 
+			id, name, description, price, category, category_id, stock,
 			image_thumbnail, image_mobile, image_tablet, image_desktop
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Exec(
+	_, err := tx.Exec(
 		query,
 		product.ID,
 		product.Name,
+		product.Description,
 		product.Price,
 		product.Category,
+		product.CategoryID,
+		product.Stock,
 		product.Image.Thumbnail,
 		product.Image.Mobile,
 		product.Image.Tablet,
 		product.Image.Desktop,
 	)
-
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return product, nil
+	if err := saveModifierGroups(tx, product.ID, product.ModifierGroups); err != nil {
+		return err
+	}
+	return saveAvailability(tx, availabilityProduct, product.ID, product.Availability)
 }
 
+// GetByID returns the product with the given ID, including soft-deleted
+// products so that historical orders can still resolve them. Callers that
+// sell the product must check DeletedAt.
 func (r *ProductRepository) GetByID(id string) (*model.Product, error) {
 	var dbProduct ProductDB
 	query := `SELECT * FROM products WHERE id = ?`
@@ -79,62 +140,448 @@ func (r *ProductRepository) GetByID(id string) (*model.Product, error) {
 		return nil, err
 	}
 
-	return &model.Product{
-		ID:       dbProduct.ID,
-		Name:     dbProduct.Name,
-		Price:    dbProduct.Price,
-		Category: dbProduct.Category,
-		Image: model.Image{
-			Thumbnail: dbProduct.ImageThumbnail,
-			Mobile:    dbProduct.ImageMobile,
-			Tablet:    dbProduct.ImageTablet,
-			Desktop:   dbProduct.ImageDesktop,
-		},
-	}, nil
+	product := dbProduct.toModel()
+	if err := r.loadDetails([]*model.Product{product}); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
 
+// GetAll returns every product that has not been deleted, ordered by name.
 func (r *ProductRepository) GetAll() ([]*model.Product, error) {
-	query := `SELECT * FROM products`
+	query := `SELECT * FROM products WHERE deleted_at IS NULL ORDER BY name, id`
 
-	rows, err := r.db.Query(query)
-	if err != nil {
+	var dbProducts []ProductDB
+	if err := r.db.Select(&dbProducts, query); err != nil {
+		return nil, err
+	}
+
+	products := make([]*model.Product, 0, len(dbProducts))
+	for _, dbProduct := range dbProducts {
+		products = append(products, dbProduct.toModel())
+	}
+
+	if err := r.loadDetails(products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// Product listing sort keys.
+const (
+	ProductSortName      = "name"
+	ProductSortPrice     = "price"
+	ProductSortCreatedAt = "created_at"
+)
+
+// productSortColumns maps sort keys to the SQL expressions they order by.
+// created_at is normalised with datetime() so cursor values compare equal to
+// the stored text regardless of how the timestamp was written.
+var productSortColumns = map[string]string{
+	ProductSortName:      "name",
+	ProductSortPrice:     "price",
+	ProductSortCreatedAt: "datetime(created_at)",
+}
+
+// ProductFilter selects and orders a page of products.
+type ProductFilter struct {
+	Category   string
+	CategoryID string
+	MinPrice   *float64
+	MaxPrice   *float64
+	Sort       string
+	Desc       bool
+	// Limit is the page size; 0 lists every product.
+	Limit  int
+	Cursor string
+
+	// AvailableAt, if set, leaves out products that cannot be ordered at
+	// that time.
+	AvailableAt *time.Time
+}
+
+// ProductPage is one page of a product listing. NextCursor is empty on the
+// last page; Total counts every product matching the filter.
+type ProductPage struct {
+	Products   []*model.Product
+	NextCursor string
+	Total      int
+}
+
+// List returns a page of non-deleted products matching filter, using keyset
+// pagination on the sort column and product ID.
+func (r *ProductRepository) List(filter ProductFilter) (*ProductPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = ProductSortName
+	}
+	column, ok := productSortColumns[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	if filter.Category != "" {
+		where = append(where, "category = ? COLLATE NOCASE")
+		args = append(args, filter.Category)
+	}
+	if filter.CategoryID != "" {
+		where = append(where, "category_id = ?")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.MinPrice != nil {
+		where = append(where, "price >= ?")
+		args = append(args, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		where = append(where, "price <= ?")
+		args = append(args, *filter.MaxPrice)
+	}
+	if filter.AvailableAt != nil {
+		cond, condArgs := availableCondition(*filter.AvailableAt)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	page := &ProductPage{}
+	countQuery := `SELECT COUNT(*) FROM products WHERE ` + strings.Join(where, " AND ")
+	if err := r.db.Get(&page.Total, countQuery, args...); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var products []*model.Product
-	for rows.Next() {
-		var dbProduct ProductDB
-
-		err = rows.Scan(
-			&dbProduct.ID,
-			&dbProduct.Name,
-			&dbProduct.Price,
-			&dbProduct.Category,
-			&dbProduct.ImageThumbnail,
-			&dbProduct.ImageMobile,
-			&dbProduct.ImageTablet,
-			&dbProduct.ImageDesktop,
-			&dbProduct.CreatedAt,
-			&dbProduct.UpdatedAt,
-		)
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor, filter.Sort, filter.Desc)
 		if err != nil {
 			return nil, err
 		}
+		cond, condArgs := keysetCondition(column, c)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	dir := "ASC"
+	if filter.Desc {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT * FROM products WHERE %s ORDER BY %s %s, id %s`,
+		strings.Join(where, " AND "), column, dir, dir)
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit+1)
+	}
+
+	var dbProducts []ProductDB
+	if err := r.db.Select(&dbProducts, query, args...); err != nil {
+		return nil, err
+	}
 
-		products = append(products, &model.Product{
-			ID:       dbProduct.ID,
-			Name:     dbProduct.Name,
-			Price:    dbProduct.Price,
-			Category: dbProduct.Category,
-			Image: model.Image{
-				Thumbnail: dbProduct.ImageThumbnail,
-				Mobile:    dbProduct.ImageMobile,
-				Tablet:    dbProduct.ImageTablet,
-				Desktop:   dbProduct.ImageDesktop,
-			},
+	if filter.Limit > 0 && len(dbProducts) > filter.Limit {
+		dbProducts = dbProducts[:filter.Limit]
+		last := dbProducts[len(dbProducts)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:  filter.Sort,
+			Desc:  filter.Desc,
+			Value: productSortValue(last, filter.Sort),
+			ID:    last.ID,
 		})
 	}
 
+	page.Products = make([]*model.Product, 0, len(dbProducts))
+	for _, dbProduct := range dbProducts {
+		page.Products = append(page.Products, dbProduct.toModel())
+	}
+
+	if err := r.loadDetails(page.Products); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func productSortValue(p ProductDB, sort string) interface{} {
+	switch sort {
+	case ProductSortPrice:
+		return p.Price
+	case ProductSortCreatedAt:
+		return sqliteTime(p.CreatedAt)
+	default:
+		return p.Name
+	}
+}
+
+// Search returns non-deleted products whose name, category or description
+// match every word of q, each word also matching as a prefix ("waf" finds
+// "Waffle"). With FTS5 results are ranked by relevance, name matches weighing
+// most; otherwise they are LIKE matches ordered by name. If availableAt is set,
+// products that cannot be ordered at that time are left out.
+func (r *ProductRepository) Search(q string, limit int, availableAt *time.Time) ([]*model.Product, error) {
+	terms := strings.FieldsFunc(strings.ToLower(q), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+	if len(terms) == 0 {
+		return []*model.Product{}, nil
+	}
+
+	var query string
+	var args []interface{}
+	fts, err := r.ftsEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"products.deleted_at IS NULL"}
+	if fts {
+		match := make([]string, len(terms))
+		for i, term := range terms {
+			match[i] = `"` + term + `"*`
+		}
+		where = append(where, "products_fts MATCH ?")
+		args = append(args, strings.Join(match, " "))
+	} else {
+		for _, term := range terms {
+			where = append(where, "(name LIKE ? OR category LIKE ? OR description LIKE ?)")
+			pattern := "%" + term + "%"
+			args = append(args, pattern, pattern, pattern)
+		}
+	}
+
+	if availableAt != nil {
+		cond, condArgs := availableCondition(*availableAt)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	if fts {
+		query = `
+			SELECT products.* FROM products_fts
+			JOIN products ON products.rowid = products_fts.rowid
+			WHERE ` + strings.Join(where, " AND ") + `
+			ORDER BY bm25(products_fts, 10.0, 5.0, 1.0), products.name
+			LIMIT ?
+		`
+	} else {
+		query = `SELECT * FROM products WHERE ` + strings.Join(where, " AND ") + ` ORDER BY name LIMIT ?`
+	}
+	args = append(args, limit)
+
+	var dbProducts []ProductDB
+	if err := r.db.Select(&dbProducts, query, args...); err != nil {
+		return nil, err
+	}
+
+	products := make([]*model.Product, 0, len(dbProducts))
+	for _, dbProduct := range dbProducts {
+		products = append(products, dbProduct.toModel())
+	}
+
+	if err := r.loadDetails(products); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
+
+// ftsEnabled reports whether SQLite was compiled with FTS5, in which case
+// InitDB maintains the products_fts index. The answer is remembered once it
+// has been found out.
+func (r *ProductRepository) ftsEnabled() (bool, error) {
+	r.ftsMu.Lock()
+	defer r.ftsMu.Unlock()
+
+	if !r.ftsKnown {
+		if err := r.db.Get(&r.fts, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`); err != nil {
+			return false, fmt.Errorf("error inspecting SQLite compile options: %w", err)
+		}
+		r.ftsKnown = true
+	}
+	return r.fts, nil
+}
+
+// loadDetails fills in the modifier groups and availability windows of
+// products.
+func (r *ProductRepository) loadDetails(products []*model.Product) error {
+	if err := r.loadModifierGroups(products); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	availability, err := loadAvailability(r.db, availabilityProduct, ids)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		product.Availability = availability[product.ID]
+	}
+	return nil
+}
+
+// Update replaces the fields of an existing, non-deleted product, except its
+// stock. It returns nil if there is no such product.
+func (r *ProductRepository) Update(product *model.Product) (*model.Product, error) {
+	found := false
+	err := inTx(r.db, func(tx *sqlx.Tx) (err error) {
+		found, err = updateProduct(tx, product)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return r.GetByID(product.ID)
+}
+
+// updateProduct replaces the fields of a non-deleted product, except its
+// stock, and its modifier groups and availability windows unless they are
+// nil. It returns false if there is no such product.
+func updateProduct(tx *sqlx.Tx, product *model.Product) (bool, error) {
+	// Check first, so that a missing product does not create its category
+	var exists bool
+	err := tx.Get(&exists, `SELECT COUNT(*) > 0 FROM products WHERE id = ? AND deleted_at IS NULL`, product.ID)
+	if err != nil {
+		return false, fmt.Errorf("error fetching product: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	if err := resolveCategory(tx, product); err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE products SET
+			name = ?, description = ?, price = ?, category = ?, category_id = ?,
+			image_thumbnail = ?, image_mobile = ?, image_tablet = ?, image_desktop = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	res, err := tx.Exec(
+		query,
+		product.Name,
+		product.Description,
+		product.Price,
+		product.Category,
+		product.CategoryID,
+		product.Image.Thumbnail,
+		product.Image.Mobile,
+		product.Image.Tablet,
+		product.Image.Desktop,
+		product.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if product.ModifierGroups != nil {
+		if err := saveModifierGroups(tx, product.ID, product.ModifierGroups); err != nil {
+			return false, err
+		}
+	}
+	if product.Availability != nil {
+		if err := saveAvailability(tx, availabilityProduct, product.ID, product.Availability); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// SetImage replaces the image URLs of a non-deleted product. It returns nil
+// if there is no such product.
+func (r *ProductRepository) SetImage(id string, image model.Image) (*model.Product, error) {
+	query := `
+		UPDATE products SET image_thumbnail = ?, image_mobile = ?, image_tablet = ?, image_desktop = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+	res, err := r.db.Exec(query, image.Thumbnail, image.Mobile, image.Tablet, image.Desktop, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+// ImageInUse reports whether a product, deleted or not, shows the image with
+// the given thumbnail URL.
+func (r *ProductRepository) ImageInUse(thumbnail string) (bool, error) {
+	var inUse bool
+	query := `SELECT EXISTS (SELECT 1 FROM products WHERE image_thumbnail = ?)`
+	if err := r.db.Get(&inUse, query, thumbnail); err != nil {
+		return false, fmt.Errorf("error checking image use: %w", err)
+	}
+	return inUse, nil
+}
+
+// SetStock sets the stock of a non-deleted product; a nil stock stops
+// tracking it. It returns nil if there is no such product.
+func (r *ProductRepository) SetStock(id string, stock *int) (*model.Product, error) {
+	res, err := r.db.Exec(`UPDATE products SET stock = ? WHERE id = ? AND deleted_at IS NULL`, stock, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+// AdjustStock changes the tracked stock of a non-deleted product by delta. It
+// returns ErrStockNotTracked if the product has no stock level and
+// ErrInsufficientStock if the stock would drop below zero, and nil if there
+// is no such product.
+func (r *ProductRepository) AdjustStock(id string, delta int) (*model.Product, error) {
+	query := `
+		UPDATE products SET stock = stock + ?
+		WHERE id = ? AND deleted_at IS NULL AND stock IS NOT NULL AND stock + ? >= 0
+	`
+	res, err := r.db.Exec(query, delta, id, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := r.GetByID(id)
+	if err != nil || product == nil || product.DeletedAt != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		if product.Stock == nil {
+			return nil, ErrStockNotTracked
+		}
+		return nil, fmt.Errorf("%w: product %s has %d left", ErrInsufficientStock, id, *product.Stock)
+	}
+	return product, nil
+}
+
+// Delete soft-deletes a product: it disappears from listings and can no
+// longer be ordered, but stays resolvable by ID. It returns false if there is
+// no such product or it was already deleted.
+func (r *ProductRepository) Delete(id string) (bool, error) {
+	query := `UPDATE products SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
+
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+func categoryNames(t *testing.T, repo *CategoryRepository) []string {
+	t.Helper()
+	categories, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	return names
+}
+
+func TestUpdateProductCategory(t *testing.T) {
+	db := newTestDB(t)
+	products := NewProductRepository(db)
+	categories := NewCategoryRepository(db)
+
+	product, err := products.Create(&model.Product{Name: "Waffle", Price: 4, Category: "Dessert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		product   model.Product
+		wantFound bool
+		want      []string
+	}{
+		{
+			name:    "missing product",
+			product: model.Product{ID: "missing", Name: "Ghost", Price: 1, Category: "Stray"},
+			want:    []string{"Dessert"},
+		},
+		{
+			name:    "deleted product",
+			product: model.Product{ID: "deleted", Name: "Gone", Price: 1, Category: "Stray"},
+			want:    []string{"Dessert"},
+		},
+		{
+			name:      "existing product",
+			product:   model.Product{ID: product.ID, Name: "Waffle", Price: 4, Category: "Breakfast"},
+			wantFound: true,
+			want:      []string{"Breakfast", "Dessert"},
+		},
+	}
+
+	deleted, err := products.Create(&model.Product{ID: "deleted", Name: "Gone", Price: 1, Category: "Dessert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE products SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := products.Update(&tt.product)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := updated != nil; found != tt.wantFound {
+				t.Errorf("Update found the product: %v, want %v", found, tt.wantFound)
+			}
+			if got := categoryNames(t, categories); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("categories = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := newTestDB(t)
+	products := NewProductRepository(db)
+	for _, product := range []model.Product{
+		{Name: "Waffle", Price: 4, Category: "Dessert"},
+		{Name: "Pancakes", Price: 5, Category: "Breakfast", Description: "With maple syrup"},
+		{Name: "Maple Latte", Price: 3, Category: "Coffee"},
+	} {
+		if _, err := products.Create(&product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"waf", []string{"Waffle"}},
+		{"dessert", []string{"Waffle"}},
+		{"maple latte", []string{"Maple Latte"}},
+		{"syrup", []string{"Pancakes"}},
+		{"tea", nil},
+		{"--", nil},
+	}
+	for _, tt := range tests {
+		found, err := products.Search(tt.q, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, product := range found {
+			got = append(got, product.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
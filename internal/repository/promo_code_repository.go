@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+var ErrPromoCodeExists = errors.New("promo code already exists")
+
+type PromoCodeRepository struct {
+	db *sqlx.DB
+}
+
+func NewPromoCodeRepository(db *sqlx.DB) *PromoCodeRepository {
+	return &PromoCodeRepository{db: db}
+}
+
+type PromoCodeDB struct {
+	Code           string     `db:"code"`
+	Type           string     `db:"type"`
+	Value          float64    `db:"value"`
+	MaxDiscount    float64    `db:"max_discount"`
+	ProductID      *string    `db:"product_id"`
+	Quantity       int        `db:"quantity"`
+	MaxRedemptions int        `db:"max_redemptions"`
+	MaxPerCustomer int        `db:"max_per_customer"`
+	StartsAt       *time.Time `db:"starts_at"`
+	EndsAt         *time.Time `db:"ends_at"`
+	Active         bool       `db:"active"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+func (p PromoCodeDB) toModel() model.PromoCode {
+	return model.PromoCode{
+		Code:           p.Code,
+		Type:           p.Type,
+		Value:          p.Value,
+		MaxDiscount:    p.MaxDiscount,
+		ProductID:      stringValue(p.ProductID),
+		Quantity:       p.Quantity,
+		MaxRedemptions: p.MaxRedemptions,
+		MaxPerCustomer: p.MaxPerCustomer,
+		StartsAt:       p.StartsAt,
+		EndsAt:         p.EndsAt,
+		Active:         p.Active,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+	}
+}
+
+func (r *PromoCodeRepository) Create(promo *model.PromoCode) (*model.PromoCode, error) {
+	query := `
+		INSERT INTO promo_codes (
+			code, type, value, max_discount, product_id, quantity,
+			max_redemptions, max_per_customer, starts_at, ends_at, active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (code) DO NOTHING
+	`
+
+	res, err := r.db.Exec(
+		query,
+		promo.Code,
+		promo.Type,
+		promo.Value,
+		promo.MaxDiscount,
+		nullString(promo.ProductID),
+		promo.Quantity,
+		promo.MaxRedemptions,
+		promo.MaxPerCustomer,
+		promo.StartsAt,
+		promo.EndsAt,
+		promo.Active,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating promo code: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, ErrPromoCodeExists
+	}
+
+	return r.GetByCode(promo.Code)
+}
+
+func (r *PromoCodeRepository) GetByCode(code string) (*model.PromoCode, error) {
+	var promoDB PromoCodeDB
+	err := r.db.Get(&promoDB, `SELECT * FROM promo_codes WHERE code = ?`, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching promo code: %w", err)
+	}
+
+	promo := promoDB.toModel()
+	return &promo, nil
+}
+
+func (r *PromoCodeRepository) List() ([]model.PromoCode, error) {
+	var promosDB []PromoCodeDB
+	err := r.db.Select(&promosDB, `SELECT * FROM promo_codes ORDER BY created_at DESC, code`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promo codes: %w", err)
+	}
+
+	promos := make([]model.PromoCode, len(promosDB))
+	for i, promoDB := range promosDB {
+		promos[i] = promoDB.toModel()
+	}
+	return promos, nil
+}
+
+// SetActive enables or disables a promo code. It returns false if the code
+// does not exist.
+func (r *PromoCodeRepository) SetActive(code string, active bool) (bool, error) {
+	res, err := r.db.Exec(`UPDATE promo_codes SET active = ? WHERE code = ?`, active, code)
+	if err != nil {
+		return false, fmt.Errorf("error updating promo code: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error updating promo code: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Delete removes a promo code. It returns false if the code does not exist.
+func (r *PromoCodeRepository) Delete(code string) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM promo_codes WHERE code = ?`, code)
+	if err != nil {
+		return false, fmt.Errorf("error deleting promo code: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error deleting promo code: %w", err)
+	}
+	return n > 0, nil
+}
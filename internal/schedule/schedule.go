@@ -0,0 +1,141 @@
+// Package schedule converts the weekly availability windows of products and
+// categories between their JSON form and per-day time slots.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+// MinutesPerDay is the End of a slot that runs until midnight.
+const MinutesPerDay = 24 * 60
+
+var dayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Slot is one window on one day of the week, in minutes since midnight. A
+// slot whose End is not after its Start runs past midnight and ends at End
+// on the following day. Window is the index of the window it came from.
+type Slot struct {
+	Window int
+	Day    time.Weekday
+	Start  int
+	End    int
+}
+
+// Parse validates windows and expands them into one slot per day. A window
+// without days applies to every day.
+func Parse(windows []model.AvailabilityWindow) ([]Slot, error) {
+	var slots []Slot
+	for i, window := range windows {
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("availability window %d: start: %w", i+1, err)
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return nil, fmt.Errorf("availability window %d: end: %w", i+1, err)
+		}
+		if start == end || start == MinutesPerDay {
+			return nil, fmt.Errorf("availability window %d: start and end must differ", i+1)
+		}
+
+		days, err := parseDays(window.Days)
+		if err != nil {
+			return nil, fmt.Errorf("availability window %d: %w", i+1, err)
+		}
+		for _, day := range days {
+			slots = append(slots, Slot{Window: i, Day: day, Start: start, End: end})
+		}
+	}
+	return slots, nil
+}
+
+// Windows groups slots back into the windows they were parsed from.
+func Windows(slots []Slot) []model.AvailabilityWindow {
+	var windows []model.AvailabilityWindow
+	index := make(map[int]int)
+	for _, slot := range slots {
+		i, ok := index[slot.Window]
+		if !ok {
+			i = len(windows)
+			index[slot.Window] = i
+			windows = append(windows, model.AvailabilityWindow{
+				Start: formatClock(slot.Start),
+				End:   formatClock(slot.End),
+			})
+		}
+		windows[i].Days = append(windows[i].Days, dayNames[slot.Day])
+	}
+
+	for i := range windows {
+		if len(windows[i].Days) == len(dayNames) {
+			windows[i].Days = nil
+		}
+	}
+	return windows
+}
+
+func parseDays(names []string) ([]time.Weekday, error) {
+	if len(names) == 0 {
+		return []time.Weekday{
+			time.Sunday, time.Monday, time.Tuesday, time.Wednesday,
+			time.Thursday, time.Friday, time.Saturday,
+		}, nil
+	}
+
+	seen := make(map[time.Weekday]bool)
+	var days []time.Weekday
+	for _, name := range names {
+		day, ok := parseDay(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// parseDay accepts English day names and their three-letter abbreviations.
+func parseDay(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, short := range dayNames {
+		if name == short || name == strings.ToLower(time.Weekday(i).String()) {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses a time of day in 24-hour "HH:MM" form; "24:00" is
+// midnight at the end of the day.
+func parseClock(s string) (int, error) {
+	if len(s) != 5 || s[2] != ':' || !isDigits(s[:2]) || !isDigits(s[3:]) {
+		return 0, errors.New(`time must be in "HH:MM" form`)
+	}
+	hour := int(s[0]-'0')*10 + int(s[1]-'0')
+	minute := int(s[3]-'0')*10 + int(s[4]-'0')
+	if hour > 24 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	return hour*60 + minute, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravip18596/order-food-online/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []model.AvailabilityWindow
+		want    []Slot
+		wantErr string
+	}{
+		{
+			name:    "daytime window",
+			windows: []model.AvailabilityWindow{{Days: []string{"mon", "Tuesday"}, Start: "11:00", End: "14:30"}},
+			want: []Slot{
+				{Window: 0, Day: time.Monday, Start: 660, End: 870},
+				{Window: 0, Day: time.Tuesday, Start: 660, End: 870},
+			},
+		},
+		{
+			name:    "overnight window ends the next day",
+			windows: []model.AvailabilityWindow{{Days: []string{"fri", "sat"}, Start: "22:00", End: "02:00"}},
+			want: []Slot{
+				{Window: 0, Day: time.Friday, Start: 1320, End: 120},
+				{Window: 0, Day: time.Saturday, Start: 1320, End: 120},
+			},
+		},
+		{
+			name:    "overnight window until midnight exactly",
+			windows: []model.AvailabilityWindow{{Days: []string{"sun"}, Start: "18:00", End: "00:00"}},
+			want:    []Slot{{Window: 0, Day: time.Sunday, Start: 1080, End: 0}},
+		},
+		{
+			name:    "window until the end of the day",
+			windows: []model.AvailabilityWindow{{Days: []string{"sun"}, Start: "18:00", End: "24:00"}},
+			want:    []Slot{{Window: 0, Day: time.Sunday, Start: 1080, End: MinutesPerDay}},
+		},
+		{
+			name:    "whole day",
+			windows: []model.AvailabilityWindow{{Days: []string{"wed"}, Start: "00:00", End: "24:00"}},
+			want:    []Slot{{Window: 0, Day: time.Wednesday, Start: 0, End: MinutesPerDay}},
+		},
+		{
+			name:    "no days means every day",
+			windows: []model.AvailabilityWindow{{Start: "23:30", End: "00:30"}},
+			want: []Slot{
+				{Window: 0, Day: time.Sunday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Monday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Tuesday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Wednesday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Thursday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Friday, Start: 1410, End: 30},
+				{Window: 0, Day: time.Saturday, Start: 1410, End: 30},
+			},
+		},
+		{
+			name: "several windows with repeated days",
+			windows: []model.AvailabilityWindow{
+				{Days: []string{"mon", "MON"}, Start: "07:00", End: "10:00"},
+				{Days: []string{"mon"}, Start: "21:00", End: "01:00"},
+			},
+			want: []Slot{
+				{Window: 0, Day: time.Monday, Start: 420, End: 600},
+				{Window: 1, Day: time.Monday, Start: 1260, End: 60},
+			},
+		},
+		{
+			name:    "start equals end",
+			windows: []model.AvailabilityWindow{{Start: "09:00", End: "09:00"}},
+			wantErr: "start and end must differ",
+		},
+		{
+			name:    "start at the end of the day",
+			windows: []model.AvailabilityWindow{{Start: "24:00", End: "02:00"}},
+			wantErr: "start and end must differ",
+		},
+		{
+			name:    "hour out of range",
+			windows: []model.AvailabilityWindow{{Start: "22:00", End: "25:00"}},
+			wantErr: "end: invalid time 25:00",
+		},
+		{
+			name:    "minutes past midnight at the end of the day",
+			windows: []model.AvailabilityWindow{{Start: "22:00", End: "24:30"}},
+			wantErr: "end: invalid time 24:30",
+		},
+		{
+			name:    "malformed time",
+			windows: []model.AvailabilityWindow{{Start: "9:00", End: "17:00"}},
+			wantErr: `start: time must be in "HH:MM" form`,
+		},
+		{
+			name: "unknown day in a later window",
+			windows: []model.AvailabilityWindow{
+				{Start: "09:00", End: "17:00"},
+				{Days: []string{"someday"}, Start: "22:00", End: "02:00"},
+			},
+			wantErr: `availability window 2: unknown day "someday"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots, err := Parse(tt.windows)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(slots, tt.want) {
+				t.Errorf("Parse = %v, want %v", slots, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowsRoundTrip(t *testing.T) {
+	windows := []model.AvailabilityWindow{
+		{Days: []string{"fri", "sat"}, Start: "22:00", End: "02:00"},
+		{Start: "06:30", End: "24:00"},
+	}
+	slots, err := Parse(windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Windows(slots); !reflect.DeepEqual(got, windows) {
+		t.Errorf("Windows(Parse(windows)) = %v, want %v", got, windows)
+	}
+}